@@ -0,0 +1,161 @@
+// Package auth authenticates the clients of the proxy and authorizes their requests.
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	"github.com/form3tech-oss/http-message-signing-proxy/proxy"
+)
+
+// method authenticates the clients with one kind of credentials. ok is false if the request has no credentials
+// of this kind, so that the next method is tried.
+type method interface {
+	authenticate(req *http.Request) (client string, ok bool, err error)
+	// header is the header holding the credentials
+	header() string
+}
+
+// authenticator tries each method in turn, the first one finding credentials in the request decides.
+type authenticator struct {
+	methods []method
+}
+
+// NewAuthenticator returns an authenticator accepting the API keys, bearer tokens and basic auth credentials
+// configured in cfg. The JWKS and htpasswd files are loaded once, a config reload loads them again.
+// The credentials found are removed from the request, the Authorization header included when bearer tokens or basic
+// auth are enabled, so that they are not forwarded upstream.
+func NewAuthenticator(cfg config.AuthConfig) (proxy.Authenticator, error) {
+	a := &authenticator{}
+	if len(cfg.APIKeys.Keys) > 0 {
+		a.methods = append(a.methods, newAPIKeyMethod(cfg.APIKeys))
+	}
+	if cfg.JWT.JWKSFilePath != "" {
+		m, err := newJWTMethod(cfg.JWT)
+		if err != nil {
+			return nil, err
+		}
+		a.methods = append(a.methods, m)
+	}
+	if cfg.Basic.HtpasswdFilePath != "" {
+		m, err := newBasicMethod(cfg.Basic)
+		if err != nil {
+			return nil, err
+		}
+		a.methods = append(a.methods, m)
+	}
+	return a, nil
+}
+
+func (a *authenticator) Authenticate(req *http.Request) (string, error) {
+	// The credentials of the methods that are not tried are removed as well
+	defer a.StripCredentials(req)
+	for _, m := range a.methods {
+		client, ok, err := m.authenticate(req)
+		if err != nil {
+			return "", proxy.NewUnauthorizedError(err)
+		}
+		if ok {
+			return client, nil
+		}
+	}
+	return "", proxy.NewUnauthorizedError(errors.New("missing credentials"))
+}
+
+func (a *authenticator) StripCredentials(req *http.Request) {
+	for _, m := range a.methods {
+		req.Header.Del(m.header())
+	}
+}
+
+// apiKeyMethod authenticates the clients with a static key set in a header.
+type apiKeyMethod struct {
+	keyHeader string
+	keys      []config.APIKeyConfig
+}
+
+func newAPIKeyMethod(cfg config.APIKeysConfig) *apiKeyMethod {
+	return &apiKeyMethod{
+		keyHeader: cfg.Header,
+		keys:      cfg.Keys,
+	}
+}
+
+func (m *apiKeyMethod) header() string {
+	return m.keyHeader
+}
+
+func (m *apiKeyMethod) authenticate(req *http.Request) (string, bool, error) {
+	key := req.Header.Get(m.keyHeader)
+	if key == "" {
+		return "", false, nil
+	}
+
+	// Every key is compared in constant time, so that the time taken does not tell which key is closest
+	client := ""
+	for _, k := range m.keys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(k.Key)) == 1 {
+			client = k.Client
+		}
+	}
+	if client == "" {
+		return "", false, errors.New("invalid API key")
+	}
+	return client, true, nil
+}
+
+// bearerToken returns the token of an Authorization header with the Bearer scheme.
+func bearerToken(req *http.Request) (string, bool) {
+	scheme, token, found := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// authorizer allows each client the requests matching one of its rules.
+type authorizer struct {
+	clients map[string][]config.AuthzRuleConfig
+}
+
+// NewAuthorizer returns an authorizer applying the rules of the clients of cfg. Clients without rules are not
+// allowed any request.
+func NewAuthorizer(cfg config.AuthConfig) proxy.Authorizer {
+	a := &authorizer{clients: map[string][]config.AuthzRuleConfig{}}
+	for _, client := range cfg.Clients {
+		a.clients[client.Name] = client.Rules
+	}
+	return a
+}
+
+func (a *authorizer) Authorize(client string, req *http.Request) error {
+	reqPath, err := proxy.NormalizePath(req)
+	if err != nil {
+		return err
+	}
+	for _, rule := range a.clients[client] {
+		if matchRule(rule, req.Method, reqPath) {
+			return nil
+		}
+	}
+	return proxy.NewForbiddenError(fmt.Errorf("client '%s' is not allowed to %s %s", client, req.Method, req.URL.Path))
+}
+
+func matchRule(rule config.AuthzRuleConfig, method string, reqPath string) bool {
+	if !proxy.MatchPathPrefix(reqPath, rule.PathPrefix) {
+		return false
+	}
+	if len(rule.Methods) == 0 {
+		return true
+	}
+	for _, ruleMethod := range rule.Methods {
+		if strings.EqualFold(ruleMethod, method) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	"github.com/form3tech-oss/http-message-signing-proxy/proxy"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuthenticator(t *testing.T) {
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("billing-password"), bcrypt.MinCost)
+	require.NoError(t, err)
+	htpasswdFile := filepath.Join(t.TempDir(), ".htpasswd")
+	require.NoError(t, os.WriteFile(htpasswdFile, []byte(
+		"# test users\n"+
+			"billing:"+string(bcryptHash)+"\n"+
+			// {SHA} hash of "reports-password"
+			"reports:{SHA}+v4r9CrF9tTdxdCTG1MaF0/EJcQ=\n",
+	), 0600))
+
+	authenticator, err := NewAuthenticator(config.AuthConfig{
+		APIKeys: config.APIKeysConfig{
+			Header: "X-API-Key",
+			Keys: []config.APIKeyConfig{
+				{Client: "billing", Key: "billing-key"},
+				{Client: "reports", Key: "reports-key"},
+			},
+		},
+		Basic: config.BasicAuthConfig{HtpasswdFilePath: htpasswdFile},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		setCredentials func(req *http.Request)
+		expectedClient string
+	}{
+		{
+			"API key",
+			func(req *http.Request) {
+				req.Header.Set("X-API-Key", "reports-key")
+			},
+			"reports",
+		},
+		{
+			"invalid API key",
+			func(req *http.Request) {
+				req.Header.Set("X-API-Key", "unknown-key")
+			},
+			"",
+		},
+		{
+			"basic auth with bcrypt hash",
+			func(req *http.Request) {
+				req.SetBasicAuth("billing", "billing-password")
+			},
+			"billing",
+		},
+		{
+			"basic auth with SHA hash",
+			func(req *http.Request) {
+				req.SetBasicAuth("reports", "reports-password")
+			},
+			"reports",
+		},
+		{
+			"wrong password",
+			func(req *http.Request) {
+				req.SetBasicAuth("billing", "reports-password")
+			},
+			"",
+		},
+		{
+			"unknown user",
+			func(req *http.Request) {
+				req.SetBasicAuth("payments", "billing-password")
+			},
+			"",
+		},
+		{
+			"API key along with basic auth",
+			func(req *http.Request) {
+				req.Header.Set("X-API-Key", "reports-key")
+				req.SetBasicAuth("billing", "billing-password")
+			},
+			"reports",
+		},
+		{
+			"missing credentials",
+			func(req *http.Request) {},
+			"",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "http://localhost/v1/payments", nil)
+			require.NoError(t, err)
+			test.setCredentials(req)
+
+			client, err := authenticator.Authenticate(req)
+			// Credentials are not forwarded to the upstream target, whichever method decided
+			require.Empty(t, req.Header.Get("X-API-Key"))
+			require.Empty(t, req.Header.Get("Authorization"))
+			if test.expectedClient == "" {
+				var unauthorizedErr *proxy.UnauthorizedError
+				require.ErrorAs(t, err, &unauthorizedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expectedClient, client)
+		})
+	}
+
+	// Credentials are removed from the requests of clients that are not authenticated by the authenticator
+	req, err := http.NewRequest(http.MethodGet, "http://localhost/v1/payments", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-API-Key", "reports-key")
+	req.SetBasicAuth("billing", "billing-password")
+	req.Header.Set("X-Request-Id", "1")
+	authenticator.StripCredentials(req)
+	require.Equal(t, http.Header{"X-Request-Id": []string{"1"}}, req.Header)
+}
+
+func TestNewAuthenticatorErrors(t *testing.T) {
+	unsupportedFile := filepath.Join(t.TempDir(), ".htpasswd")
+	require.NoError(t, os.WriteFile(unsupportedFile, []byte("billing:$apr1$salt$hash\n"), 0600))
+
+	tests := []struct {
+		name string
+		cfg  config.AuthConfig
+	}{
+		{
+			"missing htpasswd file",
+			config.AuthConfig{Basic: config.BasicAuthConfig{HtpasswdFilePath: "missing"}},
+		},
+		{
+			"unsupported htpasswd hash",
+			config.AuthConfig{Basic: config.BasicAuthConfig{HtpasswdFilePath: unsupportedFile}},
+		},
+		{
+			"missing JWKS file",
+			config.AuthConfig{JWT: config.JWTConfig{JWKSFilePath: "missing", ClientClaim: "sub"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewAuthenticator(test.cfg)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestAuthorizer(t *testing.T) {
+	authorizer := NewAuthorizer(config.AuthConfig{
+		Clients: []config.ClientConfig{
+			{
+				Name: "billing",
+				Rules: []config.AuthzRuleConfig{
+					{Methods: []string{"GET", "post"}, PathPrefix: "/v1/payments"},
+					{Methods: []string{"GET"}, PathPrefix: "/v1/accounts"},
+				},
+			},
+			{
+				Name:  "admin",
+				Rules: []config.AuthzRuleConfig{{}},
+			},
+		},
+	})
+
+	tests := []struct {
+		client  string
+		method  string
+		path    string
+		allowed bool
+	}{
+		{"billing", http.MethodPost, "/v1/payments/1", true},
+		{"billing", http.MethodGet, "/v1/accounts", true},
+		{"billing", http.MethodPost, "/v1/accounts", false},
+		{"billing", http.MethodDelete, "/v1/payments/1", false},
+		{"admin", http.MethodDelete, "/v1/accounts", true},
+		{"reports", http.MethodGet, "/v1/payments", false},
+		// Prefixes match whole segments of the normalised path
+		{"billing", http.MethodGet, "/v1/payments-admin", false},
+		{"billing", http.MethodGet, "/v1/accounts/", true},
+		{"billing", http.MethodGet, "//v1//payments/1", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.client+" "+test.method+" "+test.path, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, "http://localhost"+test.path, nil)
+			require.NoError(t, err)
+
+			err = authorizer.Authorize(test.client, req)
+			if test.allowed {
+				require.NoError(t, err)
+				return
+			}
+			var forbiddenErr *proxy.ForbiddenError
+			require.ErrorAs(t, err, &forbiddenErr)
+		})
+	}
+}
+
+func TestAuthorizerTraversal(t *testing.T) {
+	authorizer := NewAuthorizer(config.AuthConfig{
+		Clients: []config.ClientConfig{
+			{
+				Name:  "billing",
+				Rules: []config.AuthzRuleConfig{{PathPrefix: "/v1/payments"}},
+			},
+		},
+	})
+
+	// Paths that the upstream target may resolve out of the prefix are rejected
+	for _, path := range []string{
+		"/v1/payments/../admin",
+		"/v1/payments/%2e%2e/admin",
+		"/v1/payments/%2E%2E",
+		"/v1/payments/./1",
+		"/v1/payments%2f..%2fadmin",
+	} {
+		t.Run(path, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "http://localhost"+path, nil)
+			require.NoError(t, err)
+
+			err = authorizer.Authorize("billing", req)
+			var invalidReqErr *proxy.InvalidRequestError
+			require.ErrorAs(t, err, &invalidReqErr)
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1" //nolint:gosec // {SHA} htpasswd entries are supported for compatibility
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// basicMethod authenticates the clients with HTTP basic auth against the users of an htpasswd file.
+type basicMethod struct {
+	// hashes maps the users to their password hash, either bcrypt or {SHA}
+	hashes map[string]string
+}
+
+func newBasicMethod(cfg config.BasicAuthConfig) (*basicMethod, error) {
+	hashes, err := loadHtpasswd(cfg.HtpasswdFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load htpasswd file: %w", err)
+	}
+	return &basicMethod{hashes: hashes}, nil
+}
+
+func (m *basicMethod) header() string {
+	return "Authorization"
+}
+
+func (m *basicMethod) authenticate(req *http.Request) (string, bool, error) {
+	user, password, ok := req.BasicAuth()
+	if !ok {
+		return "", false, nil
+	}
+
+	hash, found := m.hashes[user]
+	if !found || !checkPassword(hash, password) {
+		return "", false, errors.New("invalid username or password")
+	}
+	return user, true, nil
+}
+
+func checkPassword(hash string, password string) bool {
+	if strings.HasPrefix(hash, "{SHA}") {
+		sum := sha1.Sum([]byte(password)) //nolint:gosec // see import
+		expected := "{SHA}" + base64.StdEncoding.EncodeToString(sum[:])
+		return subtle.ConstantTimeCompare([]byte(hash), []byte(expected)) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// loadHtpasswd reads the user:hash lines of an htpasswd file. Only bcrypt and {SHA} hashes are supported.
+func loadHtpasswd(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hashes := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		user, hash, found := strings.Cut(text, ":")
+		if !found || user == "" {
+			return nil, fmt.Errorf("invalid entry on line %d", line)
+		}
+		if !strings.HasPrefix(hash, "{SHA}") && !strings.HasPrefix(hash, "$2") {
+			return nil, fmt.Errorf("unsupported hash of user '%s', expected a bcrypt or {SHA} hash", user)
+		}
+		hashes[user] = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
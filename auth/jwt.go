@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	jose "github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+)
+
+// jwtLeeway is the clock skew tolerated checking the exp and nbf claims.
+const jwtLeeway = time.Minute
+
+// jwtAlgorithms are the signature algorithms accepted, none and the HMAC algorithms are not.
+var jwtAlgorithms = map[string]bool{
+	string(jose.RS256): true, string(jose.RS384): true, string(jose.RS512): true,
+	string(jose.PS256): true, string(jose.PS384): true, string(jose.PS512): true,
+	string(jose.ES256): true, string(jose.ES384): true, string(jose.ES512): true,
+	string(jose.EdDSA): true,
+}
+
+// jwtKey is a public key of the JWKS file, pinned to a single algorithm.
+type jwtKey struct {
+	kid string
+	alg string
+	key interface{}
+}
+
+// jwtMethod authenticates the clients with bearer tokens signed by one of the keys of a JWKS file.
+type jwtMethod struct {
+	keys        []jwtKey
+	issuer      string
+	audience    string
+	clientClaim string
+	now         func() time.Time
+}
+
+func newJWTMethod(cfg config.JWTConfig) (*jwtMethod, error) {
+	keys, err := loadJWKS(cfg.JWKSFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load JWKS file: %w", err)
+	}
+	return &jwtMethod{
+		keys:        keys,
+		issuer:      cfg.Issuer,
+		audience:    cfg.Audience,
+		clientClaim: cfg.ClientClaim,
+		now:         time.Now,
+	}, nil
+}
+
+func (m *jwtMethod) header() string {
+	return "Authorization"
+}
+
+func (m *jwtMethod) authenticate(req *http.Request) (string, bool, error) {
+	token, ok := bearerToken(req)
+	if !ok {
+		return "", false, nil
+	}
+
+	claims, err := m.verify(token)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid token: %w", err)
+	}
+	client, _ := claims[m.clientClaim].(string)
+	if client == "" {
+		return "", false, fmt.Errorf("invalid token: missing %s claim", m.clientClaim)
+	}
+	return client, true, nil
+}
+
+// verify checks the signature and the time, issuer and audience claims of a JWS compact serialized token,
+// and returns its claims. The token must expire, and be signed with the algorithm its key is pinned to.
+func (m *jwtMethod) verify(token string) (map[string]interface{}, error) {
+	tok, err := jwt.ParseSigned(token)
+	if err != nil {
+		return nil, fmt.Errorf("malformed token: %w", err)
+	}
+	if len(tok.Headers) != 1 {
+		return nil, errors.New("malformed token: a single signature is expected")
+	}
+	header := tok.Headers[0]
+	if !jwtAlgorithms[header.Algorithm] {
+		return nil, fmt.Errorf("unsupported algorithm '%s'", header.Algorithm)
+	}
+	// No extension is understood, so none can be critical
+	if _, ok := header.ExtraHeaders["crit"]; ok {
+		return nil, errors.New("unsupported crit header")
+	}
+
+	var claims jwt.Claims
+	var allClaims map[string]interface{}
+	verified := false
+	for _, k := range m.keys {
+		if (header.KeyID != "" && k.kid != header.KeyID) || k.alg != header.Algorithm {
+			continue
+		}
+		if err := tok.Claims(k.key, &claims, &allClaims); err == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, errors.New("signature does not match any key")
+	}
+
+	if claims.Expiry == nil {
+		return nil, errors.New("missing exp claim")
+	}
+	expected := jwt.Expected{Issuer: m.issuer, Time: m.now()}
+	if m.audience != "" {
+		expected.Audience = jwt.Audience{m.audience}
+	}
+	if err := claims.ValidateWithLeeway(expected, jwtLeeway); err != nil {
+		return nil, err
+	}
+	return allClaims, nil
+}
+
+// loadJWKS reads the public keys of a JWKS file, skipping the encryption keys.
+func loadJWKS(path string) ([]jwtKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var jwks jose.JSONWebKeySet
+	if err := json.Unmarshal(b, &jwks); err != nil {
+		return nil, err
+	}
+
+	var keys []jwtKey
+	for i, k := range jwks.Keys {
+		if k.Use == "enc" {
+			continue
+		}
+		public := k.Public()
+		if !public.Valid() {
+			return nil, fmt.Errorf("invalid key %d: unsupported key type", i)
+		}
+		alg, err := keyAlgorithm(public)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %d: %w", i, err)
+		}
+		keys = append(keys, jwtKey{kid: k.KeyID, alg: alg, key: public.Key})
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no key found")
+	}
+	return keys, nil
+}
+
+// keyAlgorithm returns the only algorithm the key verifies: its alg parameter, which must suit the key, or else
+// RS256 for RSA keys, the ES algorithm of their curve for EC keys and EdDSA for Ed25519 keys.
+func keyAlgorithm(k jose.JSONWebKey) (string, error) {
+	var allowed []string
+	switch key := k.Key.(type) {
+	case *rsa.PublicKey:
+		allowed = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}
+	case *ecdsa.PublicKey:
+		switch key.Curve.Params().Name {
+		case "P-256":
+			allowed = []string{"ES256"}
+		case "P-384":
+			allowed = []string{"ES384"}
+		case "P-521":
+			allowed = []string{"ES512"}
+		default:
+			return "", fmt.Errorf("unsupported curve '%s'", key.Curve.Params().Name)
+		}
+	case ed25519.PublicKey:
+		allowed = []string{"EdDSA"}
+	default:
+		return "", errors.New("unsupported key type")
+	}
+
+	if k.Algorithm == "" {
+		return allowed[0], nil
+	}
+	for _, alg := range allowed {
+		if alg == k.Algorithm {
+			return alg, nil
+		}
+	}
+	return "", fmt.Errorf("algorithm '%s' cannot be used with a %s key", k.Algorithm, strings.TrimPrefix(fmt.Sprintf("%T", k.Key), "*"))
+}
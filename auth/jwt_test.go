@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	"github.com/stretchr/testify/require"
+)
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// signToken returns a JWS compact serialized token of claims signed with key.
+func signToken(t *testing.T, alg string, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	return signTokenWithHeader(t, map[string]interface{}{"alg": alg, "kid": kid, "typ": "JWT"}, key, claims)
+}
+
+// signTokenWithHeader returns a JWS compact serialized token of claims signed with key, hashing with the size of the
+// header algorithm.
+func signTokenWithHeader(t *testing.T, header map[string]interface{}, key crypto.Signer, claims map[string]interface{}) string {
+	encodedHeader, err := json.Marshal(header)
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signed := b64(encodedHeader) + "." + b64(payload)
+
+	alg, _ := header["alg"].(string)
+	hash := crypto.SHA256
+	switch {
+	case strings.HasSuffix(alg, "384"):
+		hash = crypto.SHA384
+	case strings.HasSuffix(alg, "512"):
+		hash = crypto.SHA512
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	var signature []byte
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		if strings.HasPrefix(alg, "PS") {
+			signature, err = rsa.SignPSS(rand.Reader, key, hash, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			signature, err = rsa.SignPKCS1v15(rand.Reader, key, hash, digest)
+		}
+		require.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+		require.NoError(t, err)
+		size := (key.Curve.Params().BitSize + 7) / 8
+		signature = append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(key, []byte(signed))
+	}
+	return signed + "." + b64(signature)
+}
+
+func TestJWTAuthentication(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	noAlgKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	jwks, err := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa", "alg": "RS256", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+		{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": b64(edPub)},
+		{"kty": "RSA", "kid": "no-alg", "n": b64(noAlgKey.N.Bytes()), "e": b64(big.NewInt(int64(noAlgKey.E)).Bytes())},
+	}})
+	require.NoError(t, err)
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(jwksFile, jwks, 0600))
+
+	authenticator, err := NewAuthenticator(config.AuthConfig{
+		JWT: config.JWTConfig{
+			JWKSFilePath: jwksFile,
+			Issuer:       "https://issuer.example.com",
+			Audience:     "signing-proxy",
+			ClientClaim:  "client_id",
+		},
+	})
+	require.NoError(t, err)
+
+	validClaims := func() map[string]interface{} {
+		return map[string]interface{}{
+			"client_id": "billing",
+			"iss":       "https://issuer.example.com",
+			"aud":       []string{"other", "signing-proxy"},
+			"exp":       time.Now().Add(time.Hour).Unix(),
+		}
+	}
+	withClaim := func(name string, value interface{}) map[string]interface{} {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"RS256", signToken(t, "RS256", "rsa", rsaKey, validClaims()), true},
+		{"ES256", signToken(t, "ES256", "ec", ecKey, validClaims()), true},
+		{"EdDSA", signToken(t, "EdDSA", "ed", edKey, validClaims()), true},
+		{"without kid", signToken(t, "ES256", "", ecKey, validClaims()), true},
+		{"unknown key", signToken(t, "RS256", "", otherKey, validClaims()), false},
+		{"algorithm not allowed by the key", signToken(t, "PS256", "rsa", rsaKey, validClaims()), false},
+		{"RSA key without alg", signToken(t, "RS256", "no-alg", noAlgKey, validClaims()), true},
+		{"RSA key without alg pinned to RS256", signToken(t, "PS256", "no-alg", noAlgKey, validClaims()), false},
+		{"algorithm not matching the curve", signToken(t, "ES384", "ec", ecKey, validClaims()), false},
+		{"unknown algorithm", signToken(t, "XS256", "rsa", rsaKey, validClaims()), false},
+		{"crit header", signTokenWithHeader(t, map[string]interface{}{"alg": "RS256", "kid": "rsa", "crit": []string{"exp"}, "exp": 1}, rsaKey, validClaims()), false},
+		{"missing exp claim", signToken(t, "RS256", "rsa", rsaKey, withClaim("exp", nil)), false},
+		{"none algorithm", b64([]byte(`{"alg":"none"}`)) + "." + b64([]byte(`{"client_id":"billing"}`)) + ".", false},
+		{"expired", signToken(t, "RS256", "rsa", rsaKey, withClaim("exp", time.Now().Add(-time.Hour).Unix())), false},
+		{"not valid yet", signToken(t, "RS256", "rsa", rsaKey, withClaim("nbf", time.Now().Add(time.Hour).Unix())), false},
+		{"wrong issuer", signToken(t, "RS256", "rsa", rsaKey, withClaim("iss", "https://other.example.com")), false},
+		{"wrong audience", signToken(t, "RS256", "rsa", rsaKey, withClaim("aud", "other")), false},
+		{"missing client claim", signToken(t, "RS256", "rsa", rsaKey, withClaim("client_id", nil)), false},
+		{"malformed", "not-a-token", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "http://localhost/v1/payments", nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer "+test.token)
+
+			client, err := authenticator.Authenticate(req)
+			if !test.valid {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "billing", client)
+			require.Empty(t, req.Header.Get("Authorization"))
+		})
+	}
+}
+
+func TestLoadJWKSErrors(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rsaN := b64(rsaKey.N.Bytes())
+	rsaE := b64(big.NewInt(int64(rsaKey.E)).Bytes())
+
+	tests := []struct {
+		name string
+		keys []map[string]string
+	}{
+		{"no key", []map[string]string{}},
+		{"only encryption keys", []map[string]string{{"kty": "RSA", "use": "enc", "n": rsaN, "e": rsaE}}},
+		{"symmetric key", []map[string]string{{"kty": "oct", "k": b64([]byte("secret"))}}},
+		{"EC algorithm on an RSA key", []map[string]string{{"kty": "RSA", "alg": "ES256", "n": rsaN, "e": rsaE}}},
+		{"HMAC algorithm on an RSA key", []map[string]string{{"kty": "RSA", "alg": "HS256", "n": rsaN, "e": rsaE}}},
+		{"algorithm not matching the curve", []map[string]string{{"kty": "EC", "alg": "ES384", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			jwks, err := json.Marshal(map[string]interface{}{"keys": test.keys})
+			require.NoError(t, err)
+			jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+			require.NoError(t, os.WriteFile(jwksFile, jwks, 0600))
+
+			_, err = loadJWKS(jwksFile)
+			require.Error(t, err)
+		})
+	}
+}
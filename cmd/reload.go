@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	"github.com/form3tech-oss/http-message-signing-proxy/logger"
+	"github.com/form3tech-oss/http-message-signing-proxy/signingproxy"
+	"github.com/form3tech-oss/http-message-signing-proxy/watcher"
+	log "github.com/sirupsen/logrus"
+)
+
+// reloader reloads the config file and applies it to the running proxy on SIGHUP or when the config file changes.
+type reloader struct {
+	cfgFile   string
+	overrides []string
+	proxy     *signingproxy.Proxy
+
+	// mu prevents a SIGHUP and a config file change from reloading the config at the same time
+	mu sync.Mutex
+}
+
+func newReloader(cfgFile string, overrides []string, proxy *signingproxy.Proxy) *reloader {
+	return &reloader{
+		cfgFile:   cfgFile,
+		overrides: overrides,
+		proxy:     proxy,
+	}
+}
+
+// Reload loads the config and applies it to the proxy, along with the log config. The current config is kept if
+// the new one is invalid.
+func (r *reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := config.LoadConfig(r.cfgFile, r.overrides)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := r.proxy.Reload(cfg); err != nil {
+		var validationErrs config.ValidationErrors
+		if errors.As(err, &validationErrs) {
+			for _, validationErr := range validationErrs {
+				log.WithField("field", validationErr.Field()).Error(validationErr.Error())
+			}
+			return fmt.Errorf("invalid config, %d errors found", len(validationErrs))
+		}
+		return err
+	}
+	if err := logger.Configure(cfg.Log); err != nil {
+		return fmt.Errorf("failed to configure logger: %w", err)
+	}
+
+	log.Info("config reloaded")
+	return nil
+}
+
+// Watch reloads the config on SIGHUP, and when the config file changes if watchFile is set.
+// The returned function stops watching.
+func (r *reloader) Watch(watchFile bool) (func(), error) {
+	var fileWatcher *watcher.FileWatcher
+	if watchFile {
+		var err error
+		fileWatcher, err = watcher.NewFileWatcher(r.cfgFile, r.reloadAndLog)
+		if err != nil {
+			return nil, fmt.Errorf("failed to watch config file: %w", err)
+		}
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-hup:
+				r.reloadAndLog()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(hup)
+		close(done)
+		if fileWatcher != nil {
+			_ = fileWatcher.Close()
+		}
+	}, nil
+}
+
+func (r *reloader) reloadAndLog() {
+	if err := r.Reload(); err != nil {
+		log.WithError(err).Error("failed to reload config, keeping the current config")
+	}
+}
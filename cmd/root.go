@@ -1,13 +1,14 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"os/signal"
+	"syscall"
 
 	"github.com/form3tech-oss/http-message-signing-proxy/config"
 	"github.com/form3tech-oss/http-message-signing-proxy/logger"
-	"github.com/form3tech-oss/http-message-signing-proxy/metric"
-	"github.com/form3tech-oss/http-message-signing-proxy/proxy"
-	"github.com/form3tech-oss/http-message-signing-proxy/signer"
+	"github.com/form3tech-oss/http-message-signing-proxy/signingproxy"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -22,8 +23,10 @@ func Execute() {
 
 func NewRootCmd() *cobra.Command {
 	var (
-		cfgFile   string
-		overrides []string
+		cfgFile     string
+		overrides   []string
+		printConfig bool
+		watchConfig bool
 	)
 
 	rootCmd := &cobra.Command{
@@ -33,33 +36,59 @@ func NewRootCmd() *cobra.Command {
 				return fmt.Errorf("failed to load config: %w", err)
 			}
 
+			if printConfig {
+				return cfg.WriteRedacted(cmd.OutOrStdout())
+			}
+
+			if err := cfg.Validate(); err != nil {
+				// Print every error rather than a single line, so that all of them can be fixed at once
+				var validationErrs config.ValidationErrors
+				if errors.As(err, &validationErrs) {
+					for _, validationErr := range validationErrs {
+						cmd.PrintErrln(validationErr)
+					}
+					return fmt.Errorf("invalid config, %d errors found", len(validationErrs))
+				}
+				return fmt.Errorf("invalid config: %w", err)
+			}
+
 			err = logger.Configure(cfg.Log)
 			if err != nil {
 				return fmt.Errorf("failed to configure logger: %w", err)
 			}
 
-			reqSigner, err := signer.NewRequestSigner(cfg.Proxy.Signer)
+			p, err := signingproxy.New(signingproxy.WithConfig(cfg))
 			if err != nil {
-				return fmt.Errorf("failed to initialise request signer: %w", err)
+				return err
 			}
 
-			signingProxy, err := proxy.NewReverseProxy(cfg.Proxy.UpstreamTarget)
+			reloader := newReloader(cfgFile, overrides, p)
+			stopWatching, err := reloader.Watch(watchConfig)
 			if err != nil {
-				return fmt.Errorf("failed to create signing proxy: %w", err)
+				return err
 			}
+			defer stopWatching()
 
-			metricPublisher := metric.NewMetricPublisher(cfg.Proxy.UpstreamTarget)
-			handler := proxy.NewHandler(signingProxy, reqSigner, metricPublisher)
-			server := proxy.NewServer(cfg.Server, handler, metricPublisher)
-			server.Start()
+			// kill (no param) default send syscall.SIGTERM
+			// kill -2 is syscall.SIGINT
+			// kill -9 is syscall.SIGKILL but can't be caught, so don't need to add it
+			ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+			if err := p.Start(ctx); err != nil {
+				return err
+			}
 
-			return nil
+			// The server is shut down gracefully once a signal is received, server.shutdownGracePeriod
+			// is given to the requests in progress to finish
+			return p.Wait()
 		},
 	}
 
 	f := rootCmd.Flags()
 	f.StringVar(&cfgFile, "config", "", "path to config file")
 	f.StringArrayVar(&overrides, "set", nil, "set value for certain config fields to override config file, can be set multiple times")
+	f.BoolVar(&watchConfig, "watch-config", false, "reload the config when the config file changes, the config is also reloaded on SIGHUP")
+	f.BoolVar(&printConfig, "print-config", false, "print the resolved config, with defaults and overrides applied and secrets redacted, then exit")
 
 	return rootCmd
 }
@@ -1,34 +1,266 @@
 package config
 
+import "time"
+
 type Config struct {
 	Proxy  ProxyConfig  `mapstructure:"proxy"`
 	Server ServerConfig `mapstructure:"server"`
 	Log    LogConfig    `mapstructure:"log"`
+	// DevMode allows settings that are unsafe in production, such as skipping the upstream certificate verification
+	DevMode bool `mapstructure:"devMode"`
 }
 
 type ServerConfig struct {
-	Port                     int       `mapstructure:"port"`
-	SSL                      SSLConfig `mapstructure:"ssl"`
-	AccessControlAllowOrigin string    `mapstructure:"accessControlAllowOrigin"`
+	Port                     int           `mapstructure:"port"`
+	SSL                      SSLConfig     `mapstructure:"ssl"`
+	AccessControlAllowOrigin string        `mapstructure:"accessControlAllowOrigin"`
+	ShutdownGracePeriod      time.Duration `mapstructure:"shutdownGracePeriod"`
+	Auth                     AuthConfig    `mapstructure:"auth"`
+	TrustedProxies           []string      `mapstructure:"trustedProxies"`
 }
 
 type ProxyConfig struct {
-	UpstreamTarget string       `mapstructure:"upstreamTarget"`
-	Signer         SignerConfig `mapstructure:"signer"`
+	UpstreamTarget string          `mapstructure:"upstreamTarget"`
+	Upstream       UpstreamConfig  `mapstructure:"upstream"`
+	Signer         SignerConfig    `mapstructure:"signer"`
+	Filter         FilterConfig    `mapstructure:"filter"`
+	RateLimit      RateLimitConfig `mapstructure:"rateLimit"`
+	Routes         []RouteConfig   `mapstructure:"routes"`
 }
 
-type SSLConfig struct {
-	Enable       bool   `mapstructure:"enable"`
+type RouteConfig struct {
+	Name           string         `mapstructure:"name"`
+	Match          MatchConfig    `mapstructure:"match"`
+	UpstreamTarget string         `mapstructure:"upstreamTarget"`
+	Upstream       UpstreamConfig `mapstructure:"upstream"`
+	Signer         SignerConfig   `mapstructure:"signer"`
+}
+
+// UpstreamConfig configures the connections to the upstream target.
+type UpstreamConfig struct {
+	Transport      TransportConfig      `mapstructure:"transport"`
+	TLS            UpstreamTLSConfig    `mapstructure:"tls"`
+	Retry          RetryConfig          `mapstructure:"retry"`
+	CircuitBreaker CircuitBreakerConfig `mapstructure:"circuitBreaker"`
+}
+
+// CircuitBreakerConfig configures the circuit breaker rejecting the requests while the upstream target is failing.
+// It opens after consecutiveFailures failures in a row, or when failureRatio of at least minRequests requests fail
+// within window, then lets a request through every cooldown until one succeeds. A condition set to 0 is ignored.
+type CircuitBreakerConfig struct {
+	Enable              bool          `mapstructure:"enable"`
+	ConsecutiveFailures int           `mapstructure:"consecutiveFailures"`
+	FailureRatio        float64       `mapstructure:"failureRatio"`
+	MinRequests         int           `mapstructure:"minRequests"`
+	Window              time.Duration `mapstructure:"window"`
+	Cooldown            time.Duration `mapstructure:"cooldown"`
+}
+
+// RetryConfig configures the retries of the idempotent requests failing with a 502, 503 or 504 response or a reset
+// connection. The delay before a retry doubles from initialBackoff up to maxBackoff, with a random jitter.
+type RetryConfig struct {
+	// Retries per request after the first attempt, 0 meaning no retry
+	MaxRetries     int           `mapstructure:"maxRetries"`
+	InitialBackoff time.Duration `mapstructure:"initialBackoff"`
+	MaxBackoff     time.Duration `mapstructure:"maxBackoff"`
+	// Size in bytes of the largest body buffered to be sent again, larger bodies are sent once
+	MaxBodySize int64 `mapstructure:"maxBodySize"`
+}
+
+// UpstreamTLSConfig configures the TLS connections to an https upstream target.
+type UpstreamTLSConfig struct {
+	// Client certificate and key, for upstream targets requiring mutual TLS
 	CertFilePath string `mapstructure:"certFilePath"`
 	KeyFilePath  string `mapstructure:"keyFilePath"`
+	// CA bundle used to verify the upstream certificate instead of the system CAs
+	CAFilePath string `mapstructure:"caFilePath"`
+	// Server name sent in the SNI extension and verified against the upstream certificate, the upstream host if empty
+	ServerName         string `mapstructure:"serverName"`
+	MinVersion         string `mapstructure:"minVersion"`
+	InsecureSkipVerify bool   `mapstructure:"insecureSkipVerify"`
+}
+
+// TransportConfig bounds the time and the number of connections spent on the upstream target, 0 meaning no limit.
+type TransportConfig struct {
+	DialTimeout           time.Duration `mapstructure:"dialTimeout"`
+	KeepAlive             time.Duration `mapstructure:"keepAlive"`
+	TLSHandshakeTimeout   time.Duration `mapstructure:"tlsHandshakeTimeout"`
+	ResponseHeaderTimeout time.Duration `mapstructure:"responseHeaderTimeout"`
+	ExpectContinueTimeout time.Duration `mapstructure:"expectContinueTimeout"`
+	IdleConnTimeout       time.Duration `mapstructure:"idleConnTimeout"`
+	MaxIdleConns          int           `mapstructure:"maxIdleConns"`
+	MaxIdleConnsPerHost   int           `mapstructure:"maxIdleConnsPerHost"`
+	MaxConnsPerHost       int           `mapstructure:"maxConnsPerHost"`
+	DisableKeepAlives     bool          `mapstructure:"disableKeepAlives"`
+}
+
+type MatchConfig struct {
+	PathPrefix string            `mapstructure:"pathPrefix"`
+	Host       string            `mapstructure:"host"`
+	Header     HeaderMatchConfig `mapstructure:"header"`
+}
+
+type HeaderMatchConfig struct {
+	Name  string `mapstructure:"name"`
+	Value string `mapstructure:"value"`
+}
+
+type SSLConfig struct {
+	Enable       bool             `mapstructure:"enable"`
+	CertFilePath string           `mapstructure:"certFilePath"`
+	KeyFilePath  string           `mapstructure:"keyFilePath"`
+	ClientAuth   ClientAuthConfig `mapstructure:"clientAuth"`
+}
+
+// ClientAuthConfig requires the clients to present a certificate issued by a trusted CA, i.e. mutual TLS.
+type ClientAuthConfig struct {
+	Enable bool `mapstructure:"enable"`
+	// CAFilePath holds the PEM encoded certificates of the CAs issuing the client certificates
+	CAFilePath string `mapstructure:"caFilePath"`
+	// AllowedNames restricts the clients to the ones with one of these names as certificate subject CN or SAN,
+	// every client with a valid certificate is allowed if empty
+	AllowedNames []string `mapstructure:"allowedNames"`
+}
+
+// FilterConfig restricts the requests that are signed, whatever their route. Requests matching a deny rule are
+// rejected, as well as the requests matching no allow rule if any is set.
+type FilterConfig struct {
+	Allow []FilterRuleConfig `mapstructure:"allow"`
+	Deny  []FilterRuleConfig `mapstructure:"deny"`
+}
+
+// FilterRuleConfig matches requests by method and path, a rule without methods matches any method.
+type FilterRuleConfig struct {
+	Methods []string `mapstructure:"methods"`
+	// Path is a glob pattern, where * matches any characters but / and ** matches any characters
+	Path string `mapstructure:"path"`
+	// PathRegex is a regular expression matching the whole path, set instead of Path
+	PathRegex string `mapstructure:"pathRegex"`
+}
+
+// RateLimitConfig limits the rate of the requests that are signed. A request is rejected if any of the limits it is
+// subject to is exceeded.
+type RateLimitConfig struct {
+	Global LimitConfig `mapstructure:"global"`
+	// PerClient limits each client, identified by its authenticated name or else by its IP
+	PerClient LimitConfig `mapstructure:"perClient"`
+	// Paths limits the requests matching each rule, whatever their client
+	Paths []PathLimitConfig `mapstructure:"paths"`
+}
+
+// LimitConfig is a token bucket refilled with Rate tokens per second and holding up to Burst tokens, each request
+// taking a token. A rate of 0 means no limit and a burst of 0 means the rate rounded up.
+type LimitConfig struct {
+	Rate  float64 `mapstructure:"rate"`
+	Burst int     `mapstructure:"burst"`
+}
+
+// PathLimitConfig limits the requests matching a rule, like the ones of FilterConfig.
+type PathLimitConfig struct {
+	Methods   []string `mapstructure:"methods"`
+	Path      string   `mapstructure:"path"`
+	PathRegex string   `mapstructure:"pathRegex"`
+	Rate      float64  `mapstructure:"rate"`
+	Burst     int      `mapstructure:"burst"`
+}
+
+// Rule returns the rule matching the requests subject to the limit.
+func (c PathLimitConfig) Rule() FilterRuleConfig {
+	return FilterRuleConfig{Methods: c.Methods, Path: c.Path, PathRegex: c.PathRegex}
+}
+
+// Limit returns the limit of the requests matching the rule.
+func (c PathLimitConfig) Limit() LimitConfig {
+	return LimitConfig{Rate: c.Rate, Burst: c.Burst}
+}
+
+// AuthConfig requires the clients to authenticate, with any of the configured methods, and restricts the requests
+// each client may have signed.
+type AuthConfig struct {
+	Enable  bool            `mapstructure:"enable"`
+	APIKeys APIKeysConfig   `mapstructure:"apiKeys"`
+	JWT     JWTConfig       `mapstructure:"jwt"`
+	Basic   BasicAuthConfig `mapstructure:"basic"`
+	Clients []ClientConfig  `mapstructure:"clients"`
+}
+
+type APIKeysConfig struct {
+	// Header holds the API key in the requests
+	Header string         `mapstructure:"header"`
+	Keys   []APIKeyConfig `mapstructure:"keys"`
+}
+
+type APIKeyConfig struct {
+	Client string `mapstructure:"client"`
+	Key    string `mapstructure:"key" redact:"true"`
+}
+
+// JWTConfig authenticates the clients with bearer tokens signed by one of the keys of a JWKS file.
+type JWTConfig struct {
+	JWKSFilePath string `mapstructure:"jwksFilePath"`
+	// Issuer and Audience are checked against the iss and aud claims if set
+	Issuer   string `mapstructure:"issuer"`
+	Audience string `mapstructure:"audience"`
+	// ClientClaim is the claim holding the client name
+	ClientClaim string `mapstructure:"clientClaim"`
+}
+
+// BasicAuthConfig authenticates the clients with HTTP basic auth, the user being the client name.
+type BasicAuthConfig struct {
+	HtpasswdFilePath string `mapstructure:"htpasswdFilePath"`
+}
+
+// ClientConfig lists the requests a client may have signed, a request is allowed if it matches any of the rules.
+type ClientConfig struct {
+	Name  string            `mapstructure:"name"`
+	Rules []AuthzRuleConfig `mapstructure:"rules"`
+}
+
+// AuthzRuleConfig matches requests by method and path prefix, a rule without methods matches any method.
+type AuthzRuleConfig struct {
+	Methods    []string `mapstructure:"methods"`
+	PathPrefix string   `mapstructure:"pathPrefix"`
 }
 
 type SignerConfig struct {
-	KeyId             string        `mapstructure:"keyId"`
-	KeyFilePath       string        `mapstructure:"keyFilePath"`
-	BodyDigestAlgo    string        `mapstructure:"bodyDigestAlgo"`
-	SignatureHashAlgo string        `mapstructure:"signatureHashAlgo"`
-	Headers           HeadersConfig `mapstructure:"headers"`
+	KeyId                 string        `mapstructure:"keyId"`
+	KeyFilePath           string        `mapstructure:"keyFilePath"`
+	KeyPassphrase         string        `mapstructure:"keyPassphrase" redact:"true"`
+	KeyPassphraseEnvVar   string        `mapstructure:"keyPassphraseEnvVar"`
+	KeyPassphraseFilePath string        `mapstructure:"keyPassphraseFilePath"`
+	WatchKeyFile          bool          `mapstructure:"watchKeyFile"`
+	Keys                  []KeyConfig   `mapstructure:"keys"`
+	KeyProvider           string        `mapstructure:"keyProvider"`
+	Remote                RemoteConfig  `mapstructure:"remote"`
+	PKCS11                PKCS11Config  `mapstructure:"pkcs11"`
+	BodyDigestAlgo        string        `mapstructure:"bodyDigestAlgo"`
+	SignatureHashAlgo     string        `mapstructure:"signatureHashAlgo"`
+	Profile               string        `mapstructure:"profile"`
+	HeaderPlacement       string        `mapstructure:"headerPlacement"`
+	Headers               HeadersConfig `mapstructure:"headers"`
+	RFC9421               RFC9421Config `mapstructure:"rfc9421"`
+}
+
+// KeyConfig is a signing key used between notBefore and notAfter, zero times are unbounded.
+type KeyConfig struct {
+	KeyId       string    `mapstructure:"keyId"`
+	KeyFilePath string    `mapstructure:"keyFilePath"`
+	NotBefore   time.Time `mapstructure:"notBefore"`
+	NotAfter    time.Time `mapstructure:"notAfter"`
+}
+
+// RemoteConfig is the config of the remote signing service, used by the 'remote' key provider.
+type RemoteConfig struct {
+	URL     string        `mapstructure:"url"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// PKCS11Config is the config of the PKCS#11 token holding the keys, used by the 'pkcs11' key provider.
+type PKCS11Config struct {
+	ModulePath string `mapstructure:"modulePath"`
+	TokenLabel string `mapstructure:"tokenLabel"`
+	Pin        string `mapstructure:"pin" redact:"true"`
+	PinEnvVar  string `mapstructure:"pinEnvVar"`
 }
 
 type HeadersConfig struct {
@@ -37,6 +269,16 @@ type HeadersConfig struct {
 	SignatureHeaders     []string `mapstructure:"signatureHeaders"`
 }
 
+type RFC9421Config struct {
+	Label      string        `mapstructure:"label"`
+	Components []string      `mapstructure:"components"`
+	Created    bool          `mapstructure:"created"`
+	Expires    time.Duration `mapstructure:"expires"`
+	Nonce      bool          `mapstructure:"nonce"`
+	Tag        string        `mapstructure:"tag"`
+	IncludeAlg bool          `mapstructure:"includeAlg"`
+}
+
 type LogConfig struct {
 	Level  string `mapstructure:"level"`
 	Format string `mapstructure:"format"`
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+// signerDefaults are the defaults of every signer config, the top level one as well as the ones of the routes.
+var signerDefaults = map[string]interface{}{
+	"keyProvider":       "file",
+	"watchKeyFile":      false,
+	"bodyDigestAlgo":    "SHA-256",
+	"signatureHashAlgo": "SHA-256",
+	"profile":           "cavage",
+	"headerPlacement":   "authorization",
+	"headers": map[string]interface{}{
+		"includeDigest":        false,
+		"includeRequestTarget": false,
+		"signatureHeaders":     []string{"host", "date"},
+	},
+	"rfc9421": map[string]interface{}{
+		"label":      "sig1",
+		"components": []string{"@method", "@target-uri", "content-digest", "date"},
+		"created":    true,
+		"expires":    "0s",
+		"nonce":      false,
+		"includeAlg": false,
+	},
+	"remote": map[string]interface{}{
+		"timeout": "5s",
+	},
+}
+
+// upstreamDefaults are the defaults of every upstream config. The keep-alive, idle connection and handshake defaults
+// are the ones of http.DefaultTransport, except that more idle connections are kept as there is a single upstream.
+var upstreamDefaults = map[string]interface{}{
+	"transport": map[string]interface{}{
+		"dialTimeout":           "30s",
+		"keepAlive":             "30s",
+		"tlsHandshakeTimeout":   "10s",
+		"responseHeaderTimeout": "60s",
+		"expectContinueTimeout": "1s",
+		"idleConnTimeout":       "90s",
+		"maxIdleConns":          100,
+		"maxIdleConnsPerHost":   100,
+		"maxConnsPerHost":       0,
+		"disableKeepAlives":     false,
+	},
+	"tls": map[string]interface{}{
+		"minVersion":         "1.2",
+		"insecureSkipVerify": false,
+	},
+	"retry": map[string]interface{}{
+		"maxRetries":     0,
+		"initialBackoff": "100ms",
+		"maxBackoff":     "2s",
+		"maxBodySize":    1048576,
+	},
+	"circuitBreaker": map[string]interface{}{
+		"enable":              false,
+		"consecutiveFailures": 5,
+		"failureRatio":        0.5,
+		"minRequests":         10,
+		"window":              "10s",
+		"cooldown":            "30s",
+	},
+}
+
+// routeDefaults are the defaults of every route, filling in the upstream config even if the route does not set it.
+var routeDefaults = map[string]interface{}{
+	"upstream": upstreamDefaults,
+	"signer":   signerDefaults,
+}
+
+// defaults are the values of the fields missing from the config file, the flags and the env vars.
+var defaults = map[string]interface{}{
+	"server": map[string]interface{}{
+		"port": 8080,
+		"ssl": map[string]interface{}{
+			"enable": false,
+			"clientAuth": map[string]interface{}{
+				"enable": false,
+			},
+		},
+		"accessControlAllowOrigin": "",
+		"shutdownGracePeriod":      "5s",
+		"auth": map[string]interface{}{
+			"enable": false,
+			"apiKeys": map[string]interface{}{
+				"header": "X-API-Key",
+			},
+			"jwt": map[string]interface{}{
+				"clientClaim": "sub",
+			},
+		},
+	},
+	"proxy": map[string]interface{}{
+		"upstream": upstreamDefaults,
+		"signer":   signerDefaults,
+	},
+	"log": map[string]interface{}{
+		"level":  "info",
+		"format": "json",
+	},
+	"devMode": false,
+}
+
+// setDefaults registers the defaults in viper, so that they can also be overridden by env vars.
+func setDefaults(v *viper.Viper, prefix string, values map[string]interface{}) {
+	for key, value := range values {
+		if nested, ok := value.(map[string]interface{}); ok {
+			setDefaults(v, prefix+key+".", nested)
+			continue
+		}
+		v.SetDefault(prefix+key, value)
+	}
+}
+
+// routeDefaultsHookFunc fills in the defaults of the routes. Viper defaults do not apply to list items, so they are
+// added to the raw route config before it is decoded.
+func routeDefaultsHookFunc() mapstructure.DecodeHookFuncType {
+	routeType := reflect.TypeOf(RouteConfig{})
+	return func(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+		if to != routeType {
+			return data, nil
+		}
+		raw, ok := data.(map[string]interface{})
+		if !ok {
+			return data, nil
+		}
+		return mergeDefaults(raw, routeDefaults), nil
+	}
+}
+
+// mergeDefaults returns a copy of values with the missing keys taken from defaults. Keys are matched
+// case-insensitively, as viper lowercases the keys it reads.
+func mergeDefaults(values map[string]interface{}, defaults map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		merged[key] = value
+	}
+	for defaultKey, defaultValue := range defaults {
+		key, found := findKey(merged, defaultKey)
+		if !found {
+			merged[defaultKey] = defaultValue
+			continue
+		}
+		nestedDefaults, ok := defaultValue.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if nested, ok := merged[key].(map[string]interface{}); ok {
+			merged[key] = mergeDefaults(nested, nestedDefaults)
+		}
+	}
+	return merged
+}
+
+func findKey(values map[string]interface{}, key string) (string, bool) {
+	for k := range values {
+		if strings.EqualFold(k, key) {
+			return k, true
+		}
+	}
+	return "", false
+}
@@ -1,7 +1,11 @@
 package config
 
-// ValidationError describes an error validating the provided config.
+import "strings"
+
+// ValidationError describes an error validating the provided config. Field is the path of the invalid
+// config field, e.g. proxy.signer.keyId, and is empty if the error is not about a single field.
 type ValidationError struct {
+	field   string
 	message string
 }
 
@@ -11,6 +15,31 @@ func NewValidationError(message string) error {
 	}
 }
 
+func NewFieldValidationError(field string, message string) error {
+	return &ValidationError{
+		field:   field,
+		message: message,
+	}
+}
+
+func (e *ValidationError) Field() string {
+	return e.field
+}
+
 func (e *ValidationError) Error() string {
-	return e.message
+	if e.field == "" {
+		return e.message
+	}
+	return e.field + ": " + e.message
+}
+
+// ValidationErrors holds every error found validating the config.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
 }
@@ -0,0 +1,15 @@
+package config
+
+// GetKeys returns the configured signing keys. If none is configured, the key id and key file
+// of the signer are used as its only key, valid at any time.
+func (c SignerConfig) GetKeys() []KeyConfig {
+	if len(c.Keys) > 0 {
+		return c.Keys
+	}
+	return []KeyConfig{
+		{
+			KeyId:       c.KeyId,
+			KeyFilePath: c.KeyFilePath,
+		},
+	}
+}
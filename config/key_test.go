@@ -0,0 +1,44 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetKeys(t *testing.T) {
+	t.Run("single key", func(t *testing.T) {
+		cfg := SignerConfig{
+			KeyId:       "6f33b219-137c-467e-9a61-f61040a03363",
+			KeyFilePath: "/etc/form3/private/private.key",
+		}
+		require.Equal(t, []KeyConfig{
+			{
+				KeyId:       "6f33b219-137c-467e-9a61-f61040a03363",
+				KeyFilePath: "/etc/form3/private/private.key",
+			},
+		}, cfg.GetKeys())
+	})
+
+	t.Run("configured keys", func(t *testing.T) {
+		keys := []KeyConfig{
+			{
+				KeyId:       "key-a",
+				KeyFilePath: "/etc/form3/private/key_a.key",
+				NotAfter:    time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+			},
+			{
+				KeyId:       "key-b",
+				KeyFilePath: "/etc/form3/private/key_b.key",
+				NotBefore:   time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+			},
+		}
+		cfg := SignerConfig{
+			KeyId:       "6f33b219-137c-467e-9a61-f61040a03363",
+			KeyFilePath: "/etc/form3/private/private.key",
+			Keys:        keys,
+		}
+		require.Equal(t, keys, cfg.GetKeys())
+	})
+}
@@ -4,17 +4,22 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
 )
 
 var (
-	kvRegex = regexp.MustCompile(`([a-zA-Z\d_.]+)=(.*)`)
+	kvRegex = regexp.MustCompile(`^([a-zA-Z\d_.]+(?:\[\d+\][a-zA-Z\d_.]*)*)=(.*)$`)
 )
 
 type KV map[string]interface{}
 
-func parseKV(ss []string) (KV, error) {
+// parseKV parses key=value overrides in order. Values are kept as strings and decoded into the type of their field
+// along with the rest of the config. A key can index a list, e.g. signatureHeaders[2]=digest, in which case the
+// whole list is overridden, starting from its value in kv if set, otherwise from lookup.
+func parseKV(ss []string, lookup func(key string) interface{}) (KV, error) {
 	kv := KV{}
 	for _, s := range ss {
 		matches := kvRegex.FindAllStringSubmatch(s, -1)
@@ -22,34 +27,79 @@ func parseKV(ss []string) (KV, error) {
 			return nil, NewValidationError(fmt.Sprintf("invalid key-value flag format: '%s'", s))
 		}
 		match := matches[0]
-		kv[match[1]] = match[2]
+
+		key, path, err := splitIndexedKey(match[1])
+		if err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			kv[key] = match[2]
+			continue
+		}
+
+		current, ok := kv.get(key)
+		if !ok {
+			current = lookup(key)
+		}
+		value, err := setIndexedValue(current, path, match[2])
+		if err != nil {
+			return nil, NewFieldValidationError(match[1], err.Error())
+		}
+		kv.set(key, value)
 	}
 	return kv, nil
 }
 
+// get returns the value of key, keys being case-insensitive as in viper.
+func (kv KV) get(key string) (interface{}, bool) {
+	if k, ok := findKey(kv, key); ok {
+		return kv[k], true
+	}
+	return nil, false
+}
+
+// set sets the value of key, replacing any value set with a different case.
+func (kv KV) set(key string, value interface{}) {
+	if k, ok := findKey(kv, key); ok {
+		delete(kv, k)
+	}
+	kv[key] = value
+}
+
 func LoadConfig(configFilePath string, overrides []string) (*Config, error) {
-	viper.SetConfigFile(configFilePath)
+	// Each call uses its own viper instance, so that several configs can be loaded in the same process
+	v := viper.New()
+	v.SetConfigFile(configFilePath)
+	setDefaults(v, "", defaults)
 
-	if err := viper.ReadInConfig(); err != nil {
+	if err := v.ReadInConfig(); err != nil {
 		return nil, err
 	}
 
 	if len(overrides) > 0 {
-		kv, err := parseKV(overrides)
+		kv, err := parseKV(overrides, v.Get)
 		if err != nil {
 			return nil, err
 		}
-		err = viper.MergeConfigMap(kv)
+		err = v.MergeConfigMap(kv)
 		if err != nil {
 			return nil, fmt.Errorf("failed to override config with flags: %w", err)
 		}
 	}
 
-	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
-	viper.AutomaticEnv()
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
 
 	config := &Config{}
-	if err := viper.Unmarshal(config); err != nil {
+	// Key rotation times are RFC 3339 timestamps, e.g. 2024-01-31T00:00:00Z
+	decodeHook := mapstructure.ComposeDecodeHookFunc(
+		stringToCollectionHookFunc(),
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		mapstructure.StringToTimeHookFunc(time.RFC3339),
+		routeDefaultsHookFunc(),
+	)
+	if err := v.Unmarshal(config, viper.DecodeHook(decodeHook)); err != nil {
 		return nil, err
 	}
 
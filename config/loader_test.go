@@ -1,8 +1,8 @@
 package config
 
 import (
-	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -86,11 +86,65 @@ func TestParseKV(t *testing.T) {
 			nil,
 			&ValidationError{},
 		},
+		{
+			"list values are kept as strings",
+			[]string{
+				"key1=a,b",
+				"key2=[a, b]",
+			},
+			KV{
+				"key1": "a,b",
+				"key2": "[a, b]",
+			},
+			nil,
+		},
+		{
+			"indexed keys",
+			[]string{
+				"list[0]=a",
+				"list[1]=b",
+				"routes[0].signer.keyId=id",
+			},
+			KV{
+				"list":   []interface{}{"a", "b"},
+				"routes": []interface{}{map[string]interface{}{"signer": map[string]interface{}{"keyId": "id"}}},
+			},
+			nil,
+		},
+		{
+			"indexed key overriding a list value",
+			[]string{
+				"list=[a, b]",
+				"LIST[1]=c",
+			},
+			KV{
+				"LIST": []interface{}{"a", "c"},
+			},
+			nil,
+		},
+		{
+			"index out of range",
+			[]string{
+				"list[1]=a",
+			},
+			nil,
+			&ValidationError{},
+		},
+		{
+			"missing dot after index",
+			[]string{
+				"list[0]key=a",
+			},
+			nil,
+			&ValidationError{},
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			actual, err := parseKV(test.in)
+			actual, err := parseKV(test.in, func(string) interface{} {
+				return nil
+			})
 			if test.err != nil {
 				require.ErrorAs(t, err, &test.err)
 			} else {
@@ -101,16 +155,64 @@ func TestParseKV(t *testing.T) {
 	}
 }
 
+var defaultUpstreamConfig = UpstreamConfig{
+	Transport: TransportConfig{
+		DialTimeout:           30 * time.Second,
+		KeepAlive:             30 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 60 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   100,
+	},
+	TLS: UpstreamTLSConfig{
+		MinVersion: "1.2",
+	},
+	Retry: RetryConfig{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		MaxBodySize:    1 << 20,
+	},
+	CircuitBreaker: CircuitBreakerConfig{
+		ConsecutiveFailures: 5,
+		FailureRatio:        0.5,
+		MinRequests:         10,
+		Window:              10 * time.Second,
+		Cooldown:            30 * time.Second,
+	},
+}
+
+var defaultAuthConfig = AuthConfig{
+	APIKeys: APIKeysConfig{Header: "X-API-Key"},
+	JWT:     JWTConfig{ClientClaim: "sub"},
+}
+
+var defaultRFC9421Config = RFC9421Config{
+	Label:      "sig1",
+	Components: []string{"@method", "@target-uri", "content-digest", "date"},
+	Created:    true,
+}
+
 func TestLoadConfig(t *testing.T) {
+	// Route upstream fields that are not set keep their default
+	bankUpstreamConfig := defaultUpstreamConfig
+	bankUpstreamConfig.Transport.ResponseHeaderTimeout = 10 * time.Second
+	bankUpstreamConfig.Transport.MaxConnsPerHost = 20
 
 	expectedCfg := Config{
 		Proxy: ProxyConfig{
 			UpstreamTarget: "http://localhost",
+			Upstream:       defaultUpstreamConfig,
 			Signer: SignerConfig{
 				KeyId:             "6f33b219-137c-467e-9a61-f61040a03363",
 				KeyFilePath:       "/etc/form3/private/private.key",
+				KeyProvider:       "file",
+				Remote:            RemoteConfig{Timeout: 5 * time.Second},
 				BodyDigestAlgo:    "SHA-512",
 				SignatureHashAlgo: "SHA-256",
+				Profile:           "cavage",
+				HeaderPlacement:   "authorization",
 				Headers: HeadersConfig{
 					IncludeDigest:        true,
 					IncludeRequestTarget: true,
@@ -120,6 +222,48 @@ func TestLoadConfig(t *testing.T) {
 						"content-length",
 					},
 				},
+				RFC9421: defaultRFC9421Config,
+			},
+			Routes: []RouteConfig{
+				{
+					Name: "bank",
+					Match: MatchConfig{
+						PathPrefix: "/v1/payments",
+						Host:       "bank.example.com",
+						Header: HeaderMatchConfig{
+							Name:  "X-Counterparty",
+							Value: "bank",
+						},
+					},
+					UpstreamTarget: "https://bank.example.com",
+					Upstream:       bankUpstreamConfig,
+					Signer: SignerConfig{
+						Keys: []KeyConfig{
+							{
+								KeyId:       "5099392e-3040-40f9-ac70-ce66a9ee0ed6",
+								KeyFilePath: "/etc/form3/private/bank.key",
+								NotAfter:    time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+							},
+							{
+								KeyId:       "0a6ac3d5-8f0b-4c7d-9d0e-2b5c0a6f4c1e",
+								KeyFilePath: "/etc/form3/private/bank_next.key",
+								NotBefore:   time.Date(2024, 1, 30, 0, 0, 0, 0, time.UTC),
+							},
+						},
+						KeyProvider:       "file",
+						Remote:            RemoteConfig{Timeout: 5 * time.Second},
+						BodyDigestAlgo:    "SHA-512",
+						SignatureHashAlgo: "SHA-256",
+						Profile:           "cavage",
+						HeaderPlacement:   "authorization",
+						Headers: HeadersConfig{
+							SignatureHeaders: []string{
+								"date",
+							},
+						},
+						RFC9421: defaultRFC9421Config,
+					},
+				},
 			},
 		},
 		Server: ServerConfig{
@@ -130,6 +274,8 @@ func TestLoadConfig(t *testing.T) {
 				KeyFilePath:  "/etc/ssl/private/private.key",
 			},
 			AccessControlAllowOrigin: "*",
+			ShutdownGracePeriod:      5 * time.Second,
+			Auth:                     defaultAuthConfig,
 		},
 		Log: LogConfig{
 			Level:  "debug",
@@ -142,10 +288,97 @@ func TestLoadConfig(t *testing.T) {
 		"proxy.upstreamTarget=http://localhost",
 		"log.level=debug",
 	}
-	_ = os.Setenv("SERVER_PORT", "9090")
-	_ = os.Setenv("PROXY_SIGNER_BODYDIGESTALGO", "SHA-512")
+	t.Setenv("SERVER_PORT", "9090")
+	t.Setenv("PROXY_SIGNER_BODYDIGESTALGO", "SHA-512")
 
 	cfg, err := LoadConfig(configFile, overrides)
 	require.Nil(t, err)
 	require.Equal(t, expectedCfg, *cfg)
+	require.NoError(t, cfg.Validate())
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	expectedCfg := Config{
+		Proxy: ProxyConfig{
+			UpstreamTarget: "https://api.form3.tech/v1",
+			Upstream:       defaultUpstreamConfig,
+			Signer: SignerConfig{
+				KeyId:             "6f33b219-137c-467e-9a61-f61040a03363",
+				KeyFilePath:       "/etc/form3/private/private.key",
+				KeyProvider:       "file",
+				Remote:            RemoteConfig{Timeout: 5 * time.Second},
+				BodyDigestAlgo:    "SHA-256",
+				SignatureHashAlgo: "SHA-256",
+				Profile:           "cavage",
+				HeaderPlacement:   "authorization",
+				Headers: HeadersConfig{
+					SignatureHeaders: []string{"host", "date"},
+				},
+				RFC9421: defaultRFC9421Config,
+			},
+		},
+		Server: ServerConfig{
+			Port:                8080,
+			ShutdownGracePeriod: 5 * time.Second,
+			Auth:                defaultAuthConfig,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+	}
+
+	cfg, err := LoadConfig("config_minimal_test.yaml", nil)
+	require.NoError(t, err)
+	require.Equal(t, expectedCfg, *cfg)
+	require.NoError(t, cfg.Validate())
+}
+
+func TestLoadConfigOverrides(t *testing.T) {
+	overrides := []string{
+		"server.port=9443",
+		"server.ssl.enable=true",
+		"proxy.signer.headers.signatureHeaders[2]=digest",
+		"proxy.signer.headers.includeDigest=true",
+	}
+	t.Setenv("PROXY_SIGNER_RFC9421_COMPONENTS", "[@method, date]")
+
+	cfg, err := LoadConfig("config_minimal_test.yaml", overrides)
+	require.NoError(t, err)
+	require.Equal(t, 9443, cfg.Server.Port)
+	require.True(t, cfg.Server.SSL.Enable)
+	require.Equal(t, []string{"host", "date", "digest"}, cfg.Proxy.Signer.Headers.SignatureHeaders)
+	require.True(t, cfg.Proxy.Signer.Headers.IncludeDigest)
+	require.Equal(t, []string{"@method", "date"}, cfg.Proxy.Signer.RFC9421.Components)
+}
+
+func TestLoadConfigInvalidOverrides(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides []string
+	}{
+		{
+			"invalid integer",
+			[]string{"server.port=abc"},
+		},
+		{
+			"invalid boolean",
+			[]string{"server.ssl.enable=maybe"},
+		},
+		{
+			"index out of range",
+			[]string{"proxy.signer.headers.signatureHeaders[3]=digest"},
+		},
+		{
+			"index of a field that is not a list",
+			[]string{"proxy.signer.keyId[0]=id"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := LoadConfig("config_minimal_test.yaml", test.overrides)
+			require.Error(t, err)
+		})
+	}
 }
@@ -0,0 +1,199 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"gopkg.in/yaml.v3"
+)
+
+// splitIndexedKey splits a key such as proxy.routes[0].signer.headers.signatureHeaders[2] into the key of the
+// list, proxy.routes, and the path to the value within it, [0 signer headers signatureHeaders 2].
+func splitIndexedKey(key string) (string, []interface{}, error) {
+	i := strings.Index(key, "[")
+	if i < 0 {
+		return key, nil, nil
+	}
+
+	base, rest := key[:i], key[i:]
+	var path []interface{}
+	for rest != "" {
+		switch rest[0] {
+		case '[':
+			end := strings.Index(rest, "]")
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil {
+				return "", nil, NewValidationError(fmt.Sprintf("invalid index in key '%s'", key))
+			}
+			path = append(path, index)
+			rest = rest[end+1:]
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			if end == 0 {
+				return "", nil, NewValidationError(fmt.Sprintf("empty field name in key '%s'", key))
+			}
+			path = append(path, rest[1:end+1])
+			rest = rest[end+1:]
+		default:
+			return "", nil, NewValidationError(fmt.Sprintf("expected '.' or '[' after index in key '%s'", key))
+		}
+	}
+	return base, path, nil
+}
+
+// setIndexedValue returns a copy of node with value set at path, made of list indexes and map keys.
+// An index equal to the length of the list appends value to it.
+func setIndexedValue(node interface{}, path []interface{}, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	switch segment := path[0].(type) {
+	case int:
+		list, err := toList(node)
+		if err != nil {
+			return nil, err
+		}
+		if segment > len(list) {
+			return nil, fmt.Errorf("index %d out of range, the list has %d items", segment, len(list))
+		}
+		var item interface{}
+		if segment < len(list) {
+			item = list[segment]
+		}
+		item, err = setIndexedValue(item, path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		if segment == len(list) {
+			return append(list, item), nil
+		}
+		list[segment] = item
+		return list, nil
+	default:
+		m, err := toMap(node)
+		if err != nil {
+			return nil, err
+		}
+		key := segment.(string)
+		if existingKey, ok := findKey(m, key); ok {
+			key = existingKey
+		}
+		m[key], err = setIndexedValue(m[key], path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+}
+
+// toList returns a copy of node as a list. Strings are parsed as lists, as lists set by flags are stored unparsed.
+func toList(node interface{}) ([]interface{}, error) {
+	if node == nil {
+		return nil, nil
+	}
+	if s, ok := node.(string); ok {
+		return parseList(s)
+	}
+
+	v := reflect.ValueOf(node)
+	if v.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("value '%v' is not a list", node)
+	}
+	list := make([]interface{}, v.Len())
+	for i := range list {
+		list[i] = v.Index(i).Interface()
+	}
+	return list, nil
+}
+
+// toMap returns a copy of node as a map. Strings are parsed as maps, as maps set by flags are stored unparsed.
+func toMap(node interface{}) (map[string]interface{}, error) {
+	if node == nil {
+		return map[string]interface{}{}, nil
+	}
+	if s, ok := node.(string); ok && isFlowMap(s) {
+		return parseMap(s)
+	}
+
+	m, ok := node.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("value '%v' is not a map", node)
+	}
+	copied := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		copied[k] = v
+	}
+	return copied, nil
+}
+
+func isFlowList(s string) bool {
+	s = strings.TrimSpace(s)
+	return strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]")
+}
+
+func isFlowMap(s string) bool {
+	s = strings.TrimSpace(s)
+	return strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}")
+}
+
+// parseList parses either a comma separated list or a list written as [a, b]. Items can be quoted.
+// Lists of maps, e.g. [{name: a}, {name: b}], are parsed as YAML.
+func parseList(s string) ([]interface{}, error) {
+	s = strings.TrimSpace(s)
+	if isFlowList(s) {
+		if strings.Contains(s, "{") {
+			var list []interface{}
+			if err := yaml.Unmarshal([]byte(s), &list); err != nil {
+				return nil, NewValidationError(fmt.Sprintf("invalid list '%s': %s", s, err.Error()))
+			}
+			return list, nil
+		}
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+	if s == "" {
+		return []interface{}{}, nil
+	}
+
+	items := strings.Split(s, ",")
+	list := make([]interface{}, len(items))
+	for i, item := range items {
+		list[i] = strings.Trim(strings.TrimSpace(item), `"'`)
+	}
+	return list, nil
+}
+
+// parseMap parses a map written as YAML flow mapping, e.g. {name: a, value: b}.
+func parseMap(s string) (map[string]interface{}, error) {
+	var m map[string]interface{}
+	if err := yaml.Unmarshal([]byte(s), &m); err != nil {
+		return nil, NewValidationError(fmt.Sprintf("invalid map '%s': %s", s, err.Error()))
+	}
+	return m, nil
+}
+
+// stringToCollectionHookFunc decodes the lists and maps set as strings by flags or env vars. Other values are
+// converted to the type of their field by mapstructure, e.g. "true" to a bool or "8080" to an int, the same way
+// values read from the config file are.
+func stringToCollectionHookFunc() mapstructure.DecodeHookFuncType {
+	return func(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+		s, ok := data.(string)
+		if !ok {
+			return data, nil
+		}
+		switch {
+		case to.Kind() == reflect.Slice && to.Elem().Kind() != reflect.Uint8:
+			return parseList(s)
+		case (to.Kind() == reflect.Map || to.Kind() == reflect.Struct) && isFlowMap(s):
+			return parseMap(s)
+		default:
+			return data, nil
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redactedValue = "<redacted>"
+
+// WriteRedacted writes the config as YAML, using the same field names as the config file.
+// Secrets, i.e. fields tagged with `redact:"true"`, are replaced by a placeholder if set.
+func (c Config) WriteRedacted(w io.Writer) error {
+	node, err := encodeNode(reflect.ValueOf(c))
+	if err != nil {
+		return err
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	return encoder.Close()
+}
+
+func encodeNode(v reflect.Value) (*yaml.Node, error) {
+	switch value := v.Interface().(type) {
+	case time.Time:
+		return encodeScalar(value.Format(time.RFC3339))
+	case time.Duration:
+		return encodeScalar(value.String())
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			// Unset times, e.g. an unbounded notAfter, cannot be written as a valid timestamp
+			if t, ok := v.Field(i).Interface().(time.Time); ok && t.IsZero() {
+				continue
+			}
+			var (
+				value *yaml.Node
+				err   error
+			)
+			if field.Tag.Get("redact") == "true" && !v.Field(i).IsZero() {
+				value, err = encodeScalar(redactedValue)
+			} else {
+				value, err = encodeNode(v.Field(i))
+			}
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: field.Tag.Get("mapstructure")}, value)
+		}
+		return node, nil
+	case reflect.Slice:
+		node := &yaml.Node{Kind: yaml.SequenceNode}
+		if v.Len() == 0 {
+			node.Style = yaml.FlowStyle
+		}
+		for i := 0; i < v.Len(); i++ {
+			item, err := encodeNode(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, item)
+		}
+		return node, nil
+	default:
+		return encodeScalar(v.Interface())
+	}
+}
+
+func encodeScalar(value interface{}) (*yaml.Node, error) {
+	node := &yaml.Node{}
+	if err := node.Encode(value); err != nil {
+		return nil, fmt.Errorf("failed to encode config value: %w", err)
+	}
+	return node, nil
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteRedacted(t *testing.T) {
+	cfg, err := LoadConfig("config_test.yaml", []string{
+		"proxy.signer.keyPassphrase=secret-passphrase",
+		"proxy.signer.pkcs11.pin=1234",
+	})
+	require.NoError(t, err)
+	cfg.Proxy.RateLimit.Paths = []PathLimitConfig{{Methods: []string{"POST"}, Path: "/v1/payments", Rate: 1.5}}
+
+	var buf bytes.Buffer
+	require.NoError(t, cfg.WriteRedacted(&buf))
+	require.NotContains(t, buf.String(), "secret-passphrase")
+	require.NotContains(t, buf.String(), "1234")
+	require.Contains(t, buf.String(), "keyPassphrase: <redacted>")
+	require.Contains(t, buf.String(), "pin: <redacted>")
+
+	// The printed config can be loaded back, with the secrets replaced
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, buf.Bytes(), 0600))
+	printedCfg, err := LoadConfig(configFile, nil)
+	require.NoError(t, err)
+
+	cfg.Proxy.Signer.KeyPassphrase = redactedValue
+	cfg.Proxy.Signer.PKCS11.Pin = redactedValue
+	// Unset lists are printed as empty lists
+	cfg.Proxy.Signer.Keys = []KeyConfig{}
+	cfg.Server.SSL.ClientAuth.AllowedNames = []string{}
+	cfg.Server.TrustedProxies = []string{}
+	cfg.Server.Auth.APIKeys.Keys = []APIKeyConfig{}
+	cfg.Server.Auth.Clients = []ClientConfig{}
+	cfg.Proxy.Filter = FilterConfig{Allow: []FilterRuleConfig{}, Deny: []FilterRuleConfig{}}
+	require.Equal(t, cfg, printedCfg)
+}
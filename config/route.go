@@ -0,0 +1,20 @@
+package config
+
+// DefaultRouteName is the name of the route built from the top level upstream target and signer config.
+const DefaultRouteName = "default"
+
+// GetRoutes returns the configured routes. If none is configured, a single route matching
+// every request is built from the top level upstream target, upstream and signer config.
+func (c ProxyConfig) GetRoutes() []RouteConfig {
+	if len(c.Routes) > 0 {
+		return c.Routes
+	}
+	return []RouteConfig{
+		{
+			Name:           DefaultRouteName,
+			UpstreamTarget: c.UpstreamTarget,
+			Upstream:       c.Upstream,
+			Signer:         c.Signer,
+		},
+	}
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRoutes(t *testing.T) {
+	signerCfg := SignerConfig{
+		KeyId:       "6f33b219-137c-467e-9a61-f61040a03363",
+		KeyFilePath: "/etc/form3/private/private.key",
+	}
+
+	t.Run("default route", func(t *testing.T) {
+		cfg := ProxyConfig{
+			UpstreamTarget: "http://localhost",
+			Signer:         signerCfg,
+		}
+		require.Equal(t, []RouteConfig{
+			{
+				Name:           DefaultRouteName,
+				UpstreamTarget: "http://localhost",
+				Signer:         signerCfg,
+			},
+		}, cfg.GetRoutes())
+	})
+
+	t.Run("configured routes", func(t *testing.T) {
+		routes := []RouteConfig{
+			{
+				Name:           "bank",
+				Match:          MatchConfig{PathPrefix: "/v1/"},
+				UpstreamTarget: "https://bank.example.com",
+				Signer:         signerCfg,
+			},
+		}
+		cfg := ProxyConfig{
+			UpstreamTarget: "http://localhost",
+			Signer:         signerCfg,
+			Routes:         routes,
+		}
+		require.Equal(t, routes, cfg.GetRoutes())
+	})
+}
@@ -0,0 +1,359 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Accepted values of the enum fields, matching is case-insensitive and empty values use the default.
+var (
+	hashAlgos        = []string{"SHA-256", "SHA-384", "SHA-512"}
+	bodyDigestAlgos  = []string{"SHA-256", "SHA-512"}
+	profiles         = []string{"cavage", "rfc9421"}
+	headerPlacements = []string{"authorization", "signature"}
+	keyProviders     = []string{"file", "remote", "pkcs11"}
+	tlsVersions      = []string{"1.0", "1.1", "1.2", "1.3"}
+)
+
+// validator collects the errors found validating the config.
+type validator struct {
+	errs ValidationErrors
+	// devMode allows the settings that are unsafe in production
+	devMode bool
+}
+
+func (v *validator) addError(field string, format string, args ...interface{}) {
+	v.errs = append(v.errs, &ValidationError{
+		field:   field,
+		message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) required(field string, value string) {
+	if value == "" {
+		v.addError(field, "must be set")
+	}
+}
+
+func (v *validator) oneOf(field string, value string, allowed []string) {
+	if value == "" {
+		return
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(value, a) {
+			return
+		}
+	}
+	v.addError(field, "invalid value '%s', allowed values are [%s]", value, strings.Join(allowed, ", "))
+}
+
+// nonNegative checks durations and limits, where 0 usually means no limit.
+func (v *validator) nonNegative(field string, value int64) {
+	if value < 0 {
+		v.addError(field, "must not be negative")
+	}
+}
+
+// printableASCII checks the values serialised as structured field strings, which only allow printable ASCII
+// characters.
+func (v *validator) printableASCII(field string, value string) {
+	for i := 0; i < len(value); i++ {
+		if value[i] < 0x20 || value[i] > 0x7e {
+			v.addError(field, "must only contain printable ASCII characters")
+			return
+		}
+	}
+}
+
+func (v *validator) url(field string, value string) {
+	if value == "" {
+		v.addError(field, "must be set")
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.addError(field, "invalid url '%s', expected an absolute http or https url", value)
+	}
+}
+
+// Validate checks the whole config and returns all the errors found as ValidationErrors, nil if the config is valid.
+func (c Config) Validate() error {
+	v := &validator{devMode: c.DevMode}
+	c.Server.validate(v, "server")
+	c.Proxy.validate(v, "proxy")
+	c.Log.validate(v, "log")
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+func (c ServerConfig) validate(v *validator, path string) {
+	if c.Port < 1 || c.Port > 65535 {
+		v.addError(path+".port", "invalid port %d, expected a value between 1 and 65535", c.Port)
+	}
+	if c.SSL.Enable {
+		v.required(path+".ssl.certFilePath", c.SSL.CertFilePath)
+		v.required(path+".ssl.keyFilePath", c.SSL.KeyFilePath)
+	}
+	if c.SSL.ClientAuth.Enable {
+		if !c.SSL.Enable {
+			v.addError(path+".ssl.clientAuth.enable", "requires ssl.enable")
+		}
+		v.required(path+".ssl.clientAuth.caFilePath", c.SSL.ClientAuth.CAFilePath)
+	}
+	if c.ShutdownGracePeriod < 0 {
+		v.addError(path+".shutdownGracePeriod", "must not be negative")
+	}
+	for i, proxy := range c.TrustedProxies {
+		if net.ParseIP(proxy) != nil {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(proxy); err != nil {
+			v.addError(fmt.Sprintf("%s.trustedProxies[%d]", path, i), "invalid IP or CIDR '%s'", proxy)
+		}
+	}
+	if c.Auth.Enable {
+		c.Auth.validate(v, path+".auth")
+	}
+}
+
+func (c AuthConfig) validate(v *validator, path string) {
+	if len(c.APIKeys.Keys) == 0 && c.JWT.JWKSFilePath == "" && c.Basic.HtpasswdFilePath == "" {
+		v.addError(path, "at least one of apiKeys.keys, jwt.jwksFilePath or basic.htpasswdFilePath must be set")
+	}
+
+	if len(c.APIKeys.Keys) > 0 {
+		v.required(path+".apiKeys.header", c.APIKeys.Header)
+	}
+	keys := map[string]bool{}
+	for i, key := range c.APIKeys.Keys {
+		keyPath := fmt.Sprintf("%s.apiKeys.keys[%d]", path, i)
+		v.required(keyPath+".client", key.Client)
+		if key.Key == "" {
+			v.addError(keyPath+".key", "must be set")
+		} else if keys[key.Key] {
+			v.addError(keyPath+".key", "duplicate key")
+		}
+		keys[key.Key] = true
+	}
+	if c.JWT.JWKSFilePath != "" {
+		v.required(path+".jwt.clientClaim", c.JWT.ClientClaim)
+	}
+
+	names := map[string]bool{}
+	for i, client := range c.Clients {
+		clientPath := fmt.Sprintf("%s.clients[%d]", path, i)
+		if client.Name == "" {
+			v.addError(clientPath+".name", "must be set")
+		} else if names[client.Name] {
+			v.addError(clientPath+".name", "duplicate client name '%s'", client.Name)
+		}
+		names[client.Name] = true
+		if len(client.Rules) == 0 {
+			v.addError(clientPath+".rules", "must not be empty")
+		}
+		for j, rule := range client.Rules {
+			rule.validate(v, fmt.Sprintf("%s.rules[%d]", clientPath, j))
+		}
+	}
+}
+
+func (c AuthzRuleConfig) validate(v *validator, path string) {
+	// An empty prefix or one without a leading slash would either allow every path or none
+	if !strings.HasPrefix(c.PathPrefix, "/") {
+		v.addError(path+".pathPrefix", "must start with '/'")
+	}
+}
+
+func (c ProxyConfig) validate(v *validator, path string) {
+	for i, rule := range c.Filter.Allow {
+		rule.validate(v, fmt.Sprintf("%s.filter.allow[%d]", path, i))
+	}
+	for i, rule := range c.Filter.Deny {
+		rule.validate(v, fmt.Sprintf("%s.filter.deny[%d]", path, i))
+	}
+
+	c.RateLimit.Global.validate(v, path+".rateLimit.global")
+	c.RateLimit.PerClient.validate(v, path+".rateLimit.perClient")
+	for i, limit := range c.RateLimit.Paths {
+		limitPath := fmt.Sprintf("%s.rateLimit.paths[%d]", path, i)
+		limit.Rule().validate(v, limitPath)
+		limit.Limit().validate(v, limitPath)
+	}
+
+	// The top level upstream target and signer are only used when no route is configured
+	if len(c.Routes) == 0 {
+		v.url(path+".upstreamTarget", c.UpstreamTarget)
+		c.Upstream.validate(v, path+".upstream")
+		c.Signer.validate(v, path+".signer")
+		return
+	}
+
+	names := map[string]bool{}
+	for i, route := range c.Routes {
+		routePath := fmt.Sprintf("%s.routes[%d]", path, i)
+		if route.Name == "" {
+			v.addError(routePath+".name", "must be set")
+		} else if names[route.Name] {
+			v.addError(routePath+".name", "duplicate route name '%s'", route.Name)
+		}
+		names[route.Name] = true
+
+		if route.Match.Header.Value != "" && route.Match.Header.Name == "" {
+			v.addError(routePath+".match.header.name", "must be set when match.header.value is set")
+		}
+		v.url(routePath+".upstreamTarget", route.UpstreamTarget)
+		route.Upstream.validate(v, routePath+".upstream")
+		route.Signer.validate(v, routePath+".signer")
+	}
+}
+
+func (c FilterRuleConfig) validate(v *validator, path string) {
+	if (c.Path == "") == (c.PathRegex == "") {
+		v.addError(path, "either path or pathRegex must be set")
+	}
+	if c.PathRegex != "" {
+		if _, err := regexp.Compile(c.PathRegex); err != nil {
+			v.addError(path+".pathRegex", "invalid regular expression: %s", err)
+		}
+	}
+}
+
+func (c LimitConfig) validate(v *validator, path string) {
+	if c.Rate < 0 {
+		v.addError(path+".rate", "must not be negative")
+	}
+	v.nonNegative(path+".burst", int64(c.Burst))
+}
+
+func (c UpstreamConfig) validate(v *validator, path string) {
+	t := c.Transport
+	v.nonNegative(path+".transport.dialTimeout", int64(t.DialTimeout))
+	v.nonNegative(path+".transport.keepAlive", int64(t.KeepAlive))
+	v.nonNegative(path+".transport.tlsHandshakeTimeout", int64(t.TLSHandshakeTimeout))
+	v.nonNegative(path+".transport.responseHeaderTimeout", int64(t.ResponseHeaderTimeout))
+	v.nonNegative(path+".transport.expectContinueTimeout", int64(t.ExpectContinueTimeout))
+	v.nonNegative(path+".transport.idleConnTimeout", int64(t.IdleConnTimeout))
+	v.nonNegative(path+".transport.maxIdleConns", int64(t.MaxIdleConns))
+	v.nonNegative(path+".transport.maxIdleConnsPerHost", int64(t.MaxIdleConnsPerHost))
+	v.nonNegative(path+".transport.maxConnsPerHost", int64(t.MaxConnsPerHost))
+
+	tlsCfg := c.TLS
+	if tlsCfg.CertFilePath != "" || tlsCfg.KeyFilePath != "" {
+		v.required(path+".tls.certFilePath", tlsCfg.CertFilePath)
+		v.required(path+".tls.keyFilePath", tlsCfg.KeyFilePath)
+	}
+	v.oneOf(path+".tls.minVersion", tlsCfg.MinVersion, tlsVersions)
+	if tlsCfg.InsecureSkipVerify && !v.devMode {
+		v.addError(path+".tls.insecureSkipVerify", "only allowed when devMode is enabled")
+	}
+
+	v.nonNegative(path+".retry.maxRetries", int64(c.Retry.MaxRetries))
+	v.nonNegative(path+".retry.initialBackoff", int64(c.Retry.InitialBackoff))
+	v.nonNegative(path+".retry.maxBackoff", int64(c.Retry.MaxBackoff))
+	v.nonNegative(path+".retry.maxBodySize", c.Retry.MaxBodySize)
+
+	if cb := c.CircuitBreaker; cb.Enable {
+		if cb.ConsecutiveFailures == 0 && cb.FailureRatio == 0 {
+			v.addError(path+".circuitBreaker", "either consecutiveFailures or failureRatio must be set")
+		}
+		v.nonNegative(path+".circuitBreaker.consecutiveFailures", int64(cb.ConsecutiveFailures))
+		if cb.FailureRatio < 0 || cb.FailureRatio > 1 {
+			v.addError(path+".circuitBreaker.failureRatio", "must be between 0 and 1")
+		}
+		v.nonNegative(path+".circuitBreaker.minRequests", int64(cb.MinRequests))
+		if cb.FailureRatio > 0 && cb.Window <= 0 {
+			v.addError(path+".circuitBreaker.window", "must be set when failureRatio is set")
+		}
+		v.nonNegative(path+".circuitBreaker.cooldown", int64(cb.Cooldown))
+	}
+}
+
+func (c SignerConfig) validate(v *validator, path string) {
+	v.oneOf(path+".keyProvider", c.KeyProvider, keyProviders)
+	fileProvider := c.KeyProvider == "" || strings.EqualFold(c.KeyProvider, "file")
+
+	if len(c.Keys) == 0 {
+		v.required(path+".keyId", c.KeyId)
+		if fileProvider {
+			v.required(path+".keyFilePath", c.KeyFilePath)
+		}
+	}
+	keyIds := map[string]bool{}
+	for i, key := range c.Keys {
+		keyPath := fmt.Sprintf("%s.keys[%d]", path, i)
+		if key.KeyId == "" {
+			v.addError(keyPath+".keyId", "must be set")
+		} else if keyIds[key.KeyId] {
+			v.addError(keyPath+".keyId", "duplicate key id '%s'", key.KeyId)
+		}
+		keyIds[key.KeyId] = true
+
+		if fileProvider {
+			v.required(keyPath+".keyFilePath", key.KeyFilePath)
+		}
+		if !key.NotBefore.IsZero() && !key.NotAfter.IsZero() && !key.NotAfter.After(key.NotBefore) {
+			v.addError(keyPath+".notAfter", "must be after notBefore")
+		}
+	}
+
+	switch strings.ToLower(c.KeyProvider) {
+	case "remote":
+		v.url(path+".remote.url", c.Remote.URL)
+		if c.Remote.Timeout < 0 {
+			v.addError(path+".remote.timeout", "must not be negative")
+		}
+	case "pkcs11":
+		v.required(path+".pkcs11.modulePath", c.PKCS11.ModulePath)
+		v.required(path+".pkcs11.tokenLabel", c.PKCS11.TokenLabel)
+	}
+	if c.WatchKeyFile && !fileProvider {
+		v.addError(path+".watchKeyFile", "only supported by the 'file' key provider")
+	}
+
+	v.required(path+".bodyDigestAlgo", c.BodyDigestAlgo)
+	v.oneOf(path+".bodyDigestAlgo", c.BodyDigestAlgo, bodyDigestAlgos)
+	v.required(path+".signatureHashAlgo", c.SignatureHashAlgo)
+	v.oneOf(path+".signatureHashAlgo", c.SignatureHashAlgo, hashAlgos)
+	v.oneOf(path+".profile", c.Profile, profiles)
+	v.oneOf(path+".headerPlacement", c.HeaderPlacement, headerPlacements)
+
+	if strings.EqualFold(c.Profile, "rfc9421") {
+		if len(c.RFC9421.Components) == 0 {
+			v.addError(path+".rfc9421.components", "must not be empty")
+		}
+		if c.RFC9421.Expires < 0 {
+			v.addError(path+".rfc9421.expires", "must not be negative")
+		}
+		// Key ids, the tag and the component names are serialised as structured field strings
+		v.printableASCII(path+".keyId", c.KeyId)
+		for i, key := range c.Keys {
+			v.printableASCII(fmt.Sprintf("%s.keys[%d].keyId", path, i), key.KeyId)
+		}
+		for i, component := range c.RFC9421.Components {
+			v.printableASCII(fmt.Sprintf("%s.rfc9421.components[%d]", path, i), component)
+		}
+		v.printableASCII(path+".rfc9421.tag", c.RFC9421.Tag)
+	} else if len(c.Headers.SignatureHeaders) == 0 {
+		v.addError(path+".headers.signatureHeaders", "must not be empty")
+	}
+}
+
+func (c LogConfig) validate(v *validator, path string) {
+	if c.Level != "" {
+		if _, err := log.ParseLevel(c.Level); err != nil {
+			v.addError(path+".level", "invalid log level '%s'", c.Level)
+		}
+	}
+	switch c.Format {
+	case "", "json", "text":
+	default:
+		v.addError(path+".format", "invalid value '%s', allowed values are [json, text]", c.Format)
+	}
+}
@@ -0,0 +1,352 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func validTestConfig() Config {
+	return Config{
+		Proxy: ProxyConfig{
+			UpstreamTarget: "https://api.form3.tech/v1",
+			Signer: SignerConfig{
+				KeyId:             "6f33b219-137c-467e-9a61-f61040a03363",
+				KeyFilePath:       "/etc/form3/private/private.key",
+				BodyDigestAlgo:    "SHA-256",
+				SignatureHashAlgo: "SHA-256",
+				Headers: HeadersConfig{
+					SignatureHeaders: []string{"host", "date"},
+				},
+			},
+		},
+		Server: ServerConfig{
+			Port: 8080,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name           string
+		modify         func(cfg *Config)
+		expectedFields []string
+	}{
+		{
+			"valid config",
+			func(cfg *Config) {},
+			nil,
+		},
+		{
+			"every error is reported",
+			func(cfg *Config) {
+				cfg.Proxy.UpstreamTarget = ""
+				cfg.Server.Port = 0
+				cfg.Server.SSL.Enable = true
+				cfg.Proxy.Signer.Headers.SignatureHeaders = nil
+			},
+			[]string{
+				"server.port",
+				"server.ssl.certFilePath",
+				"server.ssl.keyFilePath",
+				"proxy.upstreamTarget",
+				"proxy.signer.headers.signatureHeaders",
+			},
+		},
+		{
+			"negative upstream transport and retry limits",
+			func(cfg *Config) {
+				cfg.Proxy.Upstream.Transport.DialTimeout = -time.Second
+				cfg.Proxy.Upstream.Transport.MaxConnsPerHost = -1
+				cfg.Proxy.Upstream.Retry.MaxRetries = -1
+				cfg.Proxy.Upstream.Retry.MaxBackoff = -time.Second
+				cfg.Proxy.Upstream.Retry.MaxBodySize = -1
+			},
+			[]string{
+				"proxy.upstream.transport.dialTimeout",
+				"proxy.upstream.transport.maxConnsPerHost",
+				"proxy.upstream.retry.maxRetries",
+				"proxy.upstream.retry.maxBackoff",
+				"proxy.upstream.retry.maxBodySize",
+			},
+		},
+		{
+			"invalid circuit breaker",
+			func(cfg *Config) {
+				cfg.Proxy.Upstream.CircuitBreaker = CircuitBreakerConfig{
+					Enable:       true,
+					FailureRatio: 1.5,
+					Cooldown:     -time.Second,
+				}
+			},
+			[]string{
+				"proxy.upstream.circuitBreaker.failureRatio",
+				"proxy.upstream.circuitBreaker.window",
+				"proxy.upstream.circuitBreaker.cooldown",
+			},
+		},
+		{
+			"circuit breaker without condition",
+			func(cfg *Config) {
+				cfg.Proxy.Upstream.CircuitBreaker = CircuitBreakerConfig{Enable: true, Cooldown: time.Second}
+			},
+			[]string{"proxy.upstream.circuitBreaker"},
+		},
+		{
+			"invalid upstream TLS config",
+			func(cfg *Config) {
+				cfg.Proxy.Upstream.TLS.CertFilePath = "/etc/form3/upstream/client.crt"
+				cfg.Proxy.Upstream.TLS.MinVersion = "1.4"
+				cfg.Proxy.Upstream.TLS.InsecureSkipVerify = true
+			},
+			[]string{
+				"proxy.upstream.tls.keyFilePath",
+				"proxy.upstream.tls.minVersion",
+				"proxy.upstream.tls.insecureSkipVerify",
+			},
+		},
+		{
+			"insecure upstream TLS in dev mode",
+			func(cfg *Config) {
+				cfg.DevMode = true
+				cfg.Proxy.Upstream.TLS.InsecureSkipVerify = true
+			},
+			nil,
+		},
+		{
+			"client auth without SSL nor CA",
+			func(cfg *Config) {
+				cfg.Server.SSL.ClientAuth.Enable = true
+			},
+			[]string{
+				"server.ssl.clientAuth.enable",
+				"server.ssl.clientAuth.caFilePath",
+			},
+		},
+		{
+			"auth without any method",
+			func(cfg *Config) {
+				cfg.Server.Auth.Enable = true
+			},
+			[]string{"server.auth"},
+		},
+		{
+			"invalid auth",
+			func(cfg *Config) {
+				cfg.Server.Auth = AuthConfig{
+					Enable: true,
+					APIKeys: APIKeysConfig{
+						Keys: []APIKeyConfig{
+							{Client: "billing", Key: "secret"},
+							{Key: "secret"},
+						},
+					},
+					JWT: JWTConfig{JWKSFilePath: "/etc/form3/auth/jwks.json"},
+					Clients: []ClientConfig{
+						{Name: "billing", Rules: []AuthzRuleConfig{{PathPrefix: "/v1/payments"}}},
+						{Name: "billing"},
+						{Name: "reports", Rules: []AuthzRuleConfig{
+							{Methods: []string{"GET"}, PathPrefix: "/v1/reports"},
+							{Methods: []string{"GET"}},
+							{PathPrefix: "v1/payments"},
+						}},
+					},
+				}
+			},
+			[]string{
+				"server.auth.apiKeys.header",
+				"server.auth.apiKeys.keys[1].client",
+				"server.auth.apiKeys.keys[1].key",
+				"server.auth.jwt.clientClaim",
+				"server.auth.clients[1].name",
+				"server.auth.clients[1].rules",
+				"server.auth.clients[2].rules[1].pathPrefix",
+				"server.auth.clients[2].rules[2].pathPrefix",
+			},
+		},
+		{
+			"invalid filter rules",
+			func(cfg *Config) {
+				cfg.Proxy.Filter = FilterConfig{
+					Allow: []FilterRuleConfig{
+						{Methods: []string{"GET"}, Path: "/v1/organisation/*"},
+						{Methods: []string{"GET"}},
+					},
+					Deny: []FilterRuleConfig{
+						{Path: "/v1/*/admin", PathRegex: "^/v1/.*/admin$"},
+						{PathRegex: "/v1/(admin"},
+					},
+				}
+			},
+			[]string{
+				"proxy.filter.allow[1]",
+				"proxy.filter.deny[0]",
+				"proxy.filter.deny[1].pathRegex",
+			},
+		},
+		{
+			"invalid rate limits",
+			func(cfg *Config) {
+				cfg.Proxy.RateLimit = RateLimitConfig{
+					Global:    LimitConfig{Rate: -1},
+					PerClient: LimitConfig{Rate: 10, Burst: -1},
+					Paths: []PathLimitConfig{
+						{Path: "/v1/transaction/payments", Rate: 5},
+						{Rate: 5},
+					},
+				}
+			},
+			[]string{
+				"proxy.rateLimit.global.rate",
+				"proxy.rateLimit.perClient.burst",
+				"proxy.rateLimit.paths[1]",
+			},
+		},
+		{
+			"negative shutdown grace period",
+			func(cfg *Config) {
+				cfg.Server.ShutdownGracePeriod = -time.Second
+			},
+			[]string{"server.shutdownGracePeriod"},
+		},
+		{
+			"invalid trusted proxies",
+			func(cfg *Config) {
+				cfg.Server.TrustedProxies = []string{"10.0.0.1", "10.0.0.0/8", "::1", "proxy.local", "10.0.0.0/33"}
+			},
+			[]string{
+				"server.trustedProxies[3]",
+				"server.trustedProxies[4]",
+			},
+		},
+		{
+			"invalid upstream target and enums",
+			func(cfg *Config) {
+				cfg.Proxy.UpstreamTarget = "localhost:8080"
+				cfg.Proxy.Signer.BodyDigestAlgo = "SHA-384"
+				cfg.Proxy.Signer.SignatureHashAlgo = "MD5"
+				cfg.Proxy.Signer.Profile = "unknown"
+				cfg.Proxy.Signer.HeaderPlacement = "cookie"
+				cfg.Log.Level = "verbose"
+				cfg.Log.Format = "xml"
+			},
+			[]string{
+				"proxy.upstreamTarget",
+				"proxy.signer.bodyDigestAlgo",
+				"proxy.signer.signatureHashAlgo",
+				"proxy.signer.profile",
+				"proxy.signer.headerPlacement",
+				"log.level",
+				"log.format",
+			},
+		},
+		{
+			"rfc9421 profile requires components",
+			func(cfg *Config) {
+				cfg.Proxy.Signer.Profile = "RFC9421"
+			},
+			[]string{
+				"proxy.signer.rfc9421.components",
+			},
+		},
+		{
+			"rfc9421 strings must be printable ASCII",
+			func(cfg *Config) {
+				cfg.Proxy.Signer.Profile = "rfc9421"
+				cfg.Proxy.Signer.KeyId = "clé"
+				cfg.Proxy.Signer.RFC9421.Components = []string{"@method", "x-request\tid"}
+				cfg.Proxy.Signer.RFC9421.Tag = "form3\n"
+			},
+			[]string{
+				"proxy.signer.keyId",
+				"proxy.signer.rfc9421.components[1]",
+				"proxy.signer.rfc9421.tag",
+			},
+		},
+		{
+			"keys",
+			func(cfg *Config) {
+				cfg.Proxy.Signer.KeyId = ""
+				cfg.Proxy.Signer.KeyFilePath = ""
+				cfg.Proxy.Signer.Keys = []KeyConfig{
+					{
+						KeyId:       "key-a",
+						KeyFilePath: "/etc/form3/private/key_a.key",
+						NotBefore:   time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+						NotAfter:    time.Date(2024, 1, 30, 0, 0, 0, 0, time.UTC),
+					},
+					{
+						KeyId: "key-a",
+					},
+				}
+			},
+			[]string{
+				"proxy.signer.keys[0].notAfter",
+				"proxy.signer.keys[1].keyId",
+				"proxy.signer.keys[1].keyFilePath",
+			},
+		},
+		{
+			"key providers",
+			func(cfg *Config) {
+				cfg.Proxy.Signer.KeyProvider = "remote"
+				cfg.Proxy.Signer.KeyFilePath = ""
+				cfg.Proxy.Signer.WatchKeyFile = true
+			},
+			[]string{
+				"proxy.signer.remote.url",
+				"proxy.signer.watchKeyFile",
+			},
+		},
+		{
+			"routes replace the top level upstream target and signer",
+			func(cfg *Config) {
+				route := RouteConfig{
+					Name:           "bank",
+					UpstreamTarget: "https://bank.example.com",
+					Signer:         cfg.Proxy.Signer,
+				}
+				cfg.Proxy.UpstreamTarget = ""
+				cfg.Proxy.Signer = SignerConfig{}
+				cfg.Proxy.Routes = []RouteConfig{route, route, {Match: MatchConfig{Header: HeaderMatchConfig{Value: "bank"}}}}
+			},
+			[]string{
+				"proxy.routes[1].name",
+				"proxy.routes[2].name",
+				"proxy.routes[2].match.header.name",
+				"proxy.routes[2].upstreamTarget",
+				"proxy.routes[2].signer.keyId",
+				"proxy.routes[2].signer.keyFilePath",
+				"proxy.routes[2].signer.bodyDigestAlgo",
+				"proxy.routes[2].signer.signatureHashAlgo",
+				"proxy.routes[2].signer.headers.signatureHeaders",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := validTestConfig()
+			test.modify(&cfg)
+
+			err := cfg.Validate()
+			if test.expectedFields == nil {
+				require.NoError(t, err)
+				return
+			}
+
+			var validationErrs ValidationErrors
+			require.ErrorAs(t, err, &validationErrs)
+			var fields []string
+			for _, validationErr := range validationErrs {
+				fields = append(fields, validationErr.Field())
+			}
+			require.Equal(t, test.expectedFields, fields)
+		})
+	}
+}
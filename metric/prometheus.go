@@ -1,107 +1,257 @@
 package metric
 
 import (
+	"strconv"
+
 	"github.com/form3tech-oss/http-message-signing-proxy/proxy"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
 const (
-	promNamespace       = "signing_proxy"
-	labelUpstreamTarget = "upstream_target"
-	labelMethod         = "method"
-	labelPath           = "path"
-)
-
-var (
-	commonLabels = []string{
-		labelUpstreamTarget,
-		labelMethod,
-		labelPath,
-	}
-	errorCounterVec = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: promNamespace,
-			Name:      "internal_error_total",
-			Help:      "Total number of internal errors",
-		},
-		commonLabels,
-	)
-	totalReqCounterVec = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: promNamespace,
-			Name:      "request_count_total",
-			Help:      "Total number of incoming requests",
-		},
-		commonLabels,
-	)
-	totalSignedReqCounterVec = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: promNamespace,
-			Name:      "signed_request_total",
-			Help:      "Total number of incoming requests that are signed",
-		},
-		commonLabels,
-	)
+	promNamespace = "signing_proxy"
+	labelRoute    = "route"
+	labelMethod   = "method"
+	labelPath     = "path"
+	labelKeyId    = "key_id"
+	labelClient   = "client"
+	labelLimit    = "limit"
+	labelType     = "type"
+	labelStatus   = "status_code"
+	labelClass    = "status_class"
 )
 
-var (
-	signingDurationHistogramVec = promauto.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Namespace: promNamespace,
-			Name:      "signing_duration_seconds",
-			Help:      "Request signing duration time in seconds",
-			// 20 buckets range from 2ms to 40ms, request signing is rather fast
-			Buckets: prometheus.LinearBuckets(0.002, 0.002, 20),
-		},
-		commonLabels,
-	)
-	requestDurationHistogramVec = promauto.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Namespace: promNamespace,
-			Name:      "request_duration_seconds",
-			Help:      "Total request duration time in seconds, including signing and upstream processing",
-			// 20 buckets range from 50ms to 30s, since upstream duration is unknown
-			Buckets: prometheus.ExponentialBucketsRange(0.05, 30, 20),
-		},
-		commonLabels,
-	)
-)
+var commonLabels = []string{
+	labelRoute,
+	labelMethod,
+	labelPath,
+}
 
 type metricPublisher struct {
-	upstreamTarget string
+	errorCounterVec              *prometheus.CounterVec
+	totalReqCounterVec           *prometheus.CounterVec
+	totalSignedReqCounterVec     *prometheus.CounterVec
+	clientReqCounterVec          *prometheus.CounterVec
+	deniedReqCounterVec          *prometheus.CounterVec
+	rateLimitedReqCounterVec     *prometheus.CounterVec
+	upstreamErrorCounterVec      *prometheus.CounterVec
+	upstreamRespCounterVec       *prometheus.CounterVec
+	keyReloadErrorCounterVec     *prometheus.CounterVec
+	activeKeyGaugeVec            *prometheus.GaugeVec
+	keyRotationGaugeVec          *prometheus.GaugeVec
+	circuitBreakerGaugeVec       *prometheus.GaugeVec
+	signingDurationHistogramVec  *prometheus.HistogramVec
+	requestDurationHistogramVec  *prometheus.HistogramVec
+	upstreamDurationHistogramVec *prometheus.HistogramVec
 }
 
-func NewMetricPublisher(upstreamTarget string) proxy.MetricPublisher {
+// NewMetricPublisher registers the proxy metrics with registerer, so that several proxies can run in the same process
+// with their own registry.
+func NewMetricPublisher(registerer prometheus.Registerer) proxy.MetricPublisher {
+	factory := promauto.With(registerer)
 	return &metricPublisher{
-		upstreamTarget: upstreamTarget,
+		errorCounterVec: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: promNamespace,
+				Name:      "internal_error_total",
+				Help:      "Total number of internal errors",
+			},
+			commonLabels,
+		),
+		totalReqCounterVec: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: promNamespace,
+				Name:      "request_count_total",
+				Help:      "Total number of incoming requests",
+			},
+			commonLabels,
+		),
+		totalSignedReqCounterVec: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: promNamespace,
+				Name:      "signed_request_total",
+				Help:      "Total number of incoming requests that are signed",
+			},
+			commonLabels,
+		),
+		clientReqCounterVec: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: promNamespace,
+				Name:      "client_request_total",
+				Help:      "Total number of incoming requests per authenticated client",
+			},
+			[]string{labelRoute, labelClient},
+		),
+		deniedReqCounterVec: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: promNamespace,
+				Name:      "denied_request_total",
+				Help:      "Total number of incoming requests denied by the filter rules",
+			},
+			commonLabels,
+		),
+		rateLimitedReqCounterVec: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: promNamespace,
+				Name:      "rate_limited_request_total",
+				Help:      "Total number of incoming requests rejected for exceeding a rate limit",
+			},
+			append(commonLabels[:len(commonLabels):len(commonLabels)], labelLimit),
+		),
+		keyReloadErrorCounterVec: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: promNamespace,
+				Name:      "key_reload_error_total",
+				Help:      "Total number of failed signer key reloads",
+			},
+			[]string{labelRoute, labelKeyId},
+		),
+		activeKeyGaugeVec: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: promNamespace,
+				Name:      "active_key",
+				Help:      "Whether the signer key is the one currently used to sign requests, 1 if active and 0 otherwise",
+			},
+			[]string{labelRoute, labelKeyId},
+		),
+		keyRotationGaugeVec: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: promNamespace,
+				Name:      "key_rotation_seconds",
+				Help:      "Seconds until the active signer key is rotated, -1 if no rotation is scheduled and 0 for inactive keys",
+			},
+			[]string{labelRoute, labelKeyId},
+		),
+		upstreamErrorCounterVec: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: promNamespace,
+				Name:      "upstream_error_total",
+				Help:      "Total number of requests that could not be forwarded to the upstream target, by error type",
+			},
+			[]string{labelRoute, labelType},
+		),
+		upstreamRespCounterVec: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: promNamespace,
+				Name:      "upstream_response_total",
+				Help:      "Total number of responses received from the upstream target, by status class, retries included",
+			},
+			[]string{labelRoute, labelMethod, labelClass},
+		),
+		circuitBreakerGaugeVec: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: promNamespace,
+				Name:      "circuit_breaker_state",
+				Help:      "State of the circuit breaker of the upstream target, 0 if closed, 1 if half-open and 2 if open",
+			},
+			[]string{labelRoute},
+		),
+		signingDurationHistogramVec: factory.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: promNamespace,
+				Name:      "signing_duration_seconds",
+				Help:      "Request signing duration time in seconds",
+				// 20 buckets range from 2ms to 40ms, request signing is rather fast
+				Buckets: prometheus.LinearBuckets(0.002, 0.002, 20),
+			},
+			commonLabels,
+		),
+		requestDurationHistogramVec: factory.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: promNamespace,
+				Name:      "request_duration_seconds",
+				Help:      "Total request duration time in seconds, including signing and upstream processing",
+				// 20 buckets range from 50ms to 30s, since upstream duration is unknown
+				Buckets: prometheus.ExponentialBucketsRange(0.05, 30, 20),
+			},
+			append(commonLabels[:len(commonLabels):len(commonLabels)], labelStatus),
+		),
+		upstreamDurationHistogramVec: factory.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: promNamespace,
+				Name:      "upstream_duration_seconds",
+				Help:      "Time in seconds until the upstream target responds, for each attempt",
+				Buckets:   prometheus.ExponentialBucketsRange(0.05, 30, 20),
+			},
+			[]string{labelRoute, labelMethod, labelClass},
+		),
 	}
 }
 
-func (m *metricPublisher) IncrementTotalRequestCount(method string, path string) {
-	totalReqCounterVec.With(m.getCommonLabels(method, path)).Inc()
+func (m *metricPublisher) IncrementTotalRequestCount(route string, method string, path string) {
+	m.totalReqCounterVec.With(m.getCommonLabels(route, method, path)).Inc()
+}
+
+func (m *metricPublisher) IncrementSignedRequestCount(route string, method string, path string) {
+	m.totalSignedReqCounterVec.With(m.getCommonLabels(route, method, path)).Inc()
+}
+
+func (m *metricPublisher) IncrementInternalErrorCount(route string, method string, path string) {
+	m.errorCounterVec.With(m.getCommonLabels(route, method, path)).Inc()
+}
+
+func (m *metricPublisher) MeasureSigningDuration(route string, method string, path string, duration float64) {
+	m.signingDurationHistogramVec.With(m.getCommonLabels(route, method, path)).Observe(duration)
+}
+
+func (m *metricPublisher) MeasureTotalDuration(route string, method string, path string, statusCode int, duration float64) {
+	labels := m.getCommonLabels(route, method, path)
+	labels[labelStatus] = strconv.Itoa(statusCode)
+	m.requestDurationHistogramVec.With(labels).Observe(duration)
 }
 
-func (m *metricPublisher) IncrementSignedRequestCount(method string, path string) {
-	totalSignedReqCounterVec.With(m.getCommonLabels(method, path)).Inc()
+func (m *metricPublisher) MeasureUpstreamDuration(route string, method string, statusClass string, duration float64) {
+	labels := prometheus.Labels{labelRoute: route, labelMethod: method, labelClass: statusClass}
+	m.upstreamDurationHistogramVec.With(labels).Observe(duration)
 }
 
-func (m *metricPublisher) IncrementInternalErrorCount(method string, path string) {
-	errorCounterVec.With(m.getCommonLabels(method, path)).Inc()
+func (m *metricPublisher) IncrementUpstreamResponseCount(route string, method string, statusClass string) {
+	labels := prometheus.Labels{labelRoute: route, labelMethod: method, labelClass: statusClass}
+	m.upstreamRespCounterVec.With(labels).Inc()
 }
 
-func (m *metricPublisher) MeasureSigningDuration(method string, path string, duration float64) {
-	signingDurationHistogramVec.With(m.getCommonLabels(method, path)).Observe(duration)
+func (m *metricPublisher) IncrementClientRequestCount(route string, client string) {
+	m.clientReqCounterVec.With(prometheus.Labels{labelRoute: route, labelClient: client}).Inc()
+}
+
+func (m *metricPublisher) IncrementDeniedRequestCount(route string, method string, path string) {
+	m.deniedReqCounterVec.With(m.getCommonLabels(route, method, path)).Inc()
+}
+
+func (m *metricPublisher) IncrementRateLimitedRequestCount(route string, method string, path string, limit string) {
+	labels := m.getCommonLabels(route, method, path)
+	labels[labelLimit] = limit
+	m.rateLimitedReqCounterVec.With(labels).Inc()
+}
+
+func (m *metricPublisher) SetCircuitBreakerState(route string, state proxy.CircuitState) {
+	m.circuitBreakerGaugeVec.With(prometheus.Labels{labelRoute: route}).Set(float64(state))
+}
+
+func (m *metricPublisher) IncrementUpstreamErrorCount(route string, errType string) {
+	m.upstreamErrorCounterVec.With(prometheus.Labels{labelRoute: route, labelType: errType}).Inc()
+}
+
+func (m *metricPublisher) IncrementKeyReloadErrorCount(route string, keyId string) {
+	m.keyReloadErrorCounterVec.With(prometheus.Labels{labelRoute: route, labelKeyId: keyId}).Inc()
+}
+
+func (m *metricPublisher) SetActiveKey(route string, keyId string, active bool) {
+	value := float64(0)
+	if active {
+		value = 1
+	}
+	m.activeKeyGaugeVec.With(prometheus.Labels{labelRoute: route, labelKeyId: keyId}).Set(value)
 }
 
-func (m *metricPublisher) MeasureTotalDuration(method string, path string, duration float64) {
-	requestDurationHistogramVec.With(m.getCommonLabels(method, path)).Observe(duration)
+func (m *metricPublisher) SetSecondsUntilKeyRotation(route string, keyId string, seconds float64) {
+	m.keyRotationGaugeVec.With(prometheus.Labels{labelRoute: route, labelKeyId: keyId}).Set(seconds)
 }
 
-func (m *metricPublisher) getCommonLabels(method string, path string) prometheus.Labels {
+func (m *metricPublisher) getCommonLabels(route string, method string, path string) prometheus.Labels {
 	return prometheus.Labels{
-		labelUpstreamTarget: m.upstreamTarget,
-		labelMethod:         method,
-		labelPath:           path,
+		labelRoute:  route,
+		labelMethod: method,
+		labelPath:   path,
 	}
 }
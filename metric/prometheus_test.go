@@ -0,0 +1,46 @@
+package metric
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricPublisherRegistries(t *testing.T) {
+	// Publishers with their own registry do not conflict nor share their metrics
+	registry1 := prometheus.NewRegistry()
+	publisher1 := NewMetricPublisher(registry1)
+	registry2 := prometheus.NewRegistry()
+	publisher2 := NewMetricPublisher(registry2)
+
+	publisher1.IncrementTotalRequestCount("default", "GET", "/")
+	publisher1.IncrementTotalRequestCount("default", "GET", "/")
+	publisher2.IncrementTotalRequestCount("default", "GET", "/")
+
+	count, err := testutil.GatherAndCount(registry1, "signing_proxy_request_count_total")
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	require.Equal(t, float64(2), testutil.ToFloat64(publisher1.(*metricPublisher).totalReqCounterVec))
+	require.Equal(t, float64(1), testutil.ToFloat64(publisher2.(*metricPublisher).totalReqCounterVec))
+
+	// Registering twice with the same registry fails
+	require.Panics(t, func() {
+		NewMetricPublisher(registry1)
+	})
+}
+
+func TestMetricPublisherKeyMetricsByRoute(t *testing.T) {
+	// Routes sharing a key id do not overwrite each other's key metrics
+	publisher := NewMetricPublisher(prometheus.NewRegistry()).(*metricPublisher)
+	publisher.SetActiveKey("route-a", "key-1", true)
+	publisher.SetActiveKey("route-b", "key-1", false)
+	publisher.SetSecondsUntilKeyRotation("route-a", "key-1", 60)
+	publisher.SetSecondsUntilKeyRotation("route-b", "key-1", 0)
+
+	require.Equal(t, float64(1), testutil.ToFloat64(publisher.activeKeyGaugeVec.WithLabelValues("route-a", "key-1")))
+	require.Equal(t, float64(0), testutil.ToFloat64(publisher.activeKeyGaugeVec.WithLabelValues("route-b", "key-1")))
+	require.Equal(t, float64(60), testutil.ToFloat64(publisher.keyRotationGaugeVec.WithLabelValues("route-a", "key-1")))
+	require.Equal(t, float64(0), testutil.ToFloat64(publisher.keyRotationGaugeVec.WithLabelValues("route-b", "key-1")))
+}
@@ -0,0 +1,17 @@
+package proxy
+
+import "net/http"
+
+// Authenticator identifies the client sending a request. The credentials are removed from the request, so that
+// they are not forwarded to the upstream target.
+type Authenticator interface {
+	Authenticate(req *http.Request) (string, error)
+	// StripCredentials removes the headers of every kind of credentials the authenticator accepts, also from the
+	// requests of clients identified otherwise, e.g. by their certificate.
+	StripCredentials(req *http.Request)
+}
+
+// Authorizer decides whether a client may have a request signed.
+type Authorizer interface {
+	Authorize(client string, req *http.Request) error
+}
@@ -0,0 +1,191 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// CircuitState is the state of a circuit breaker, published as the value of the circuit breaker state metric.
+type CircuitState int
+
+const (
+	// CircuitClosed lets the requests through.
+	CircuitClosed CircuitState = iota
+	// CircuitHalfOpen lets a single request through to probe the upstream target.
+	CircuitHalfOpen
+	// CircuitOpen rejects the requests without forwarding them.
+	CircuitOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "open"
+	}
+}
+
+// CircuitBreaker rejects the requests to an upstream target that keeps failing, so that they fail fast instead of
+// waiting on the upstream target.
+type CircuitBreaker struct {
+	route           string
+	cfg             config.CircuitBreakerConfig
+	metricPublisher MetricPublisher
+	now             func() time.Time
+
+	mu    sync.Mutex
+	state CircuitState
+	// openedAt is when the breaker opened, or when the last probe was let through while half-open
+	openedAt            time.Time
+	consecutiveFailures int
+	windowStart         time.Time
+	windowRequests      int
+	windowFailures      int
+}
+
+func NewCircuitBreaker(route string, cfg config.CircuitBreakerConfig, metricPublisher MetricPublisher) *CircuitBreaker {
+	metricPublisher.SetCircuitBreakerState(route, CircuitClosed)
+	return &CircuitBreaker{
+		route:           route,
+		cfg:             cfg,
+		metricPublisher: metricPublisher,
+		now:             time.Now,
+	}
+}
+
+// Allow reports whether a request can be forwarded. Once the cooldown has elapsed, an open breaker becomes half-open
+// and lets a request through, then another one every cooldown until the outcome of one is recorded. If a request is
+// rejected, Allow returns the time until the next request may be let through.
+func (b *CircuitBreaker) Allow() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitClosed {
+		return true, 0
+	}
+	now := b.now()
+	if elapsed := now.Sub(b.openedAt); elapsed < b.cfg.Cooldown {
+		return false, b.cfg.Cooldown - elapsed
+	}
+	b.openedAt = now
+	b.setState(CircuitHalfOpen)
+	return true, 0
+}
+
+// Record records the outcome of a request to the upstream target, opening or closing the breaker accordingly.
+func (b *CircuitBreaker) Record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+
+	switch b.state {
+	case CircuitHalfOpen:
+		if failed {
+			b.open(now)
+		} else {
+			b.reset(now)
+			b.setState(CircuitClosed)
+		}
+		return
+	case CircuitOpen:
+		return
+	}
+
+	if now.Sub(b.windowStart) >= b.cfg.Window {
+		b.windowStart = now
+		b.windowRequests = 0
+		b.windowFailures = 0
+	}
+	b.windowRequests++
+	if failed {
+		b.consecutiveFailures++
+		b.windowFailures++
+	} else {
+		b.consecutiveFailures = 0
+	}
+
+	if b.cfg.ConsecutiveFailures > 0 && b.consecutiveFailures >= b.cfg.ConsecutiveFailures {
+		b.open(now)
+		return
+	}
+	// The ratio is only meaningful once the window has enough requests, which may be reached by a success
+	if b.cfg.FailureRatio > 0 && b.windowRequests >= b.cfg.MinRequests &&
+		float64(b.windowFailures)/float64(b.windowRequests) >= b.cfg.FailureRatio {
+		b.open(now)
+	}
+}
+
+func (b *CircuitBreaker) open(now time.Time) {
+	b.openedAt = now
+	b.reset(now)
+	b.setState(CircuitOpen)
+}
+
+func (b *CircuitBreaker) reset(now time.Time) {
+	b.consecutiveFailures = 0
+	b.windowStart = now
+	b.windowRequests = 0
+	b.windowFailures = 0
+}
+
+func (b *CircuitBreaker) setState(state CircuitState) {
+	if state == b.state {
+		return
+	}
+	entry := log.WithFields(log.Fields{
+		"route": b.route,
+		"from":  b.state.String(),
+		"to":    state.String(),
+	})
+	if state == CircuitOpen {
+		entry.Warn("circuit breaker opened, requests to the upstream target are rejected")
+	} else {
+		entry.Info("circuit breaker state changed")
+	}
+	b.state = state
+	b.metricPublisher.SetCircuitBreakerState(b.route, state)
+}
+
+// breakerTransport records the outcome of each request to the upstream target in the circuit breaker.
+type breakerTransport struct {
+	next    http.RoundTripper
+	breaker *CircuitBreaker
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	// Requests cancelled by the client say nothing about the upstream target
+	if !errors.Is(err, context.Canceled) {
+		t.breaker.Record(isUpstreamFailure(resp, err))
+	}
+	return resp, err
+}
+
+func (t *breakerTransport) CloseIdleConnections() {
+	if closer, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// isUpstreamFailure reports whether the upstream target failed to handle the request, either because it could not
+// be reached or because it or a gateway in front of it is unavailable.
+func isUpstreamFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,225 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	"github.com/form3tech-oss/http-message-signing-proxy/test"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.CircuitBreakerConfig
+		failures []bool
+		expected CircuitState
+	}{
+		{
+			name:     "opens after consecutive failures",
+			cfg:      config.CircuitBreakerConfig{ConsecutiveFailures: 3},
+			failures: []bool{true, true, true},
+			expected: CircuitOpen,
+		},
+		{
+			name:     "a success resets the consecutive failures",
+			cfg:      config.CircuitBreakerConfig{ConsecutiveFailures: 3},
+			failures: []bool{true, true, false, true, true},
+			expected: CircuitClosed,
+		},
+		{
+			name:     "opens when the failure ratio is reached",
+			cfg:      config.CircuitBreakerConfig{FailureRatio: 0.5, MinRequests: 4, Window: time.Minute},
+			failures: []bool{true, false, true, false},
+			expected: CircuitOpen,
+		},
+		{
+			name:     "failure ratio requires a minimum of requests",
+			cfg:      config.CircuitBreakerConfig{FailureRatio: 0.5, MinRequests: 4, Window: time.Minute},
+			failures: []bool{true, true, true},
+			expected: CircuitClosed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockMetricPublisher := NewMockMetricPublisher(mockCtrl)
+			mockMetricPublisher.EXPECT().SetCircuitBreakerState("test", gomock.Any()).AnyTimes()
+
+			breaker := NewCircuitBreaker("test", tt.cfg, mockMetricPublisher)
+			for _, failed := range tt.failures {
+				breaker.Record(failed)
+			}
+			require.Equal(t, tt.expected, breaker.state)
+		})
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockMetricPublisher := NewMockMetricPublisher(mockCtrl)
+	// State changes are published
+	gomock.InOrder(
+		mockMetricPublisher.EXPECT().SetCircuitBreakerState("test", CircuitClosed),
+		mockMetricPublisher.EXPECT().SetCircuitBreakerState("test", CircuitOpen),
+		mockMetricPublisher.EXPECT().SetCircuitBreakerState("test", CircuitHalfOpen),
+		mockMetricPublisher.EXPECT().SetCircuitBreakerState("test", CircuitOpen),
+		mockMetricPublisher.EXPECT().SetCircuitBreakerState("test", CircuitHalfOpen),
+		mockMetricPublisher.EXPECT().SetCircuitBreakerState("test", CircuitClosed),
+	)
+
+	breaker := NewCircuitBreaker("test", config.CircuitBreakerConfig{
+		ConsecutiveFailures: 1,
+		Cooldown:            30 * time.Second,
+	}, mockMetricPublisher)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	breaker.now = func() time.Time { return now }
+
+	breaker.Record(true)
+	ok, wait := breaker.Allow()
+	require.False(t, ok)
+	require.Equal(t, 30*time.Second, wait)
+
+	// A single request probes the upstream target once the cooldown has elapsed
+	now = now.Add(30 * time.Second)
+	ok, _ = breaker.Allow()
+	require.True(t, ok)
+	ok, wait = breaker.Allow()
+	require.False(t, ok)
+	require.Equal(t, 30*time.Second, wait)
+
+	// The breaker opens again if the probe fails
+	breaker.Record(true)
+	require.Equal(t, CircuitOpen, breaker.state)
+
+	// and closes if it succeeds
+	now = now.Add(30 * time.Second)
+	ok, _ = breaker.Allow()
+	require.True(t, ok)
+	breaker.Record(false)
+	require.Equal(t, CircuitClosed, breaker.state)
+	ok, _ = breaker.Allow()
+	require.True(t, ok)
+}
+
+func TestHandlerCircuitBreaker(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// Requests rejected by the breaker are signed, but neither forwarded nor counted as signed
+	mockReqSigner := NewMockRequestSigner(mockCtrl)
+	mockReqSigner.EXPECT().SignRequest(gomock.Any()).DoAndReturn(func(r *http.Request) (*http.Request, error) {
+		return r, nil
+	}).Times(3)
+	mockMetricPublisher := NewMockMetricPublisher(mockCtrl)
+	mockMetricPublisher.EXPECT().IncrementTotalRequestCount("test", http.MethodGet, "/payments/1").Times(3)
+	mockMetricPublisher.EXPECT().MeasureTotalDuration("test", http.MethodGet, "/payments/1", gomock.Any(), gomock.Any()).Times(3)
+	mockMetricPublisher.EXPECT().MeasureSigningDuration("test", http.MethodGet, "/payments/1", gomock.Any()).Times(2)
+	mockMetricPublisher.EXPECT().IncrementSignedRequestCount("test", http.MethodGet, "/payments/1").Times(2)
+	mockMetricPublisher.EXPECT().MeasureUpstreamDuration("test", http.MethodGet, gomock.Any(), gomock.Any()).AnyTimes()
+	mockMetricPublisher.EXPECT().IncrementUpstreamResponseCount("test", http.MethodGet, gomock.Any()).AnyTimes()
+	mockMetricPublisher.EXPECT().SetCircuitBreakerState("test", gomock.Any()).AnyTimes()
+
+	// Test upstream target that is unavailable
+	targetSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer targetSrv.Close()
+
+	route, err := NewRoute(config.RouteConfig{
+		Name:           "test",
+		UpstreamTarget: targetSrv.URL,
+		Upstream: config.UpstreamConfig{
+			CircuitBreaker: config.CircuitBreakerConfig{Enable: true, ConsecutiveFailures: 2, Cooldown: time.Minute},
+		},
+	}, mockReqSigner, mockMetricPublisher)
+	require.NoError(t, err)
+
+	h := NewHandler([]*Route{route}, mockMetricPublisher)
+	_, e := gin.CreateTestContext(nil)
+	e.NoRoute(h.SelectRoute, LogAndMetricsMiddleware(mockMetricPublisher), h.ForwardRequest)
+
+	serve := func() *test.TestResponseRecorder {
+		w := test.NewTestResponseRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/payments/1", nil)
+		require.NoError(t, err)
+		e.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(t, http.StatusServiceUnavailable, serve().Code)
+	require.Equal(t, http.StatusServiceUnavailable, serve().Code)
+
+	// The breaker is open, requests fail fast
+	w := serve()
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, "60", w.Header().Get("Retry-After"))
+	require.Equal(t, `{"error":"circuit breaker of route 'test' is open, the upstream target is unavailable"}`, w.Body.String())
+}
+
+func TestHandlerCircuitBreakerProbe(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// The first probe cannot be signed
+	mockReqSigner := NewMockRequestSigner(mockCtrl)
+	gomock.InOrder(
+		mockReqSigner.EXPECT().SignRequest(gomock.Any()).DoAndReturn(func(r *http.Request) (*http.Request, error) {
+			return r, nil
+		}),
+		mockReqSigner.EXPECT().SignRequest(gomock.Any()).Return(nil, NewInvalidRequestError(errors.New("missing header"))),
+		mockReqSigner.EXPECT().SignRequest(gomock.Any()).DoAndReturn(func(r *http.Request) (*http.Request, error) {
+			return r, nil
+		}),
+	)
+	mockMetricPublisher := mockMetricPublisher(mockCtrl, "test", http.MethodGet, "/payments/1")
+	mockMetricPublisher.EXPECT().SetCircuitBreakerState("test", gomock.Any()).AnyTimes()
+
+	// Test upstream target that is unavailable, then recovers
+	upstreamStatus := http.StatusServiceUnavailable
+	targetSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(upstreamStatus)
+	}))
+	defer targetSrv.Close()
+
+	route, err := NewRoute(config.RouteConfig{
+		Name:           "test",
+		UpstreamTarget: targetSrv.URL,
+		Upstream: config.UpstreamConfig{
+			CircuitBreaker: config.CircuitBreakerConfig{Enable: true, ConsecutiveFailures: 1, Cooldown: time.Minute},
+		},
+	}, mockReqSigner, mockMetricPublisher)
+	require.NoError(t, err)
+	now := time.Now()
+	route.Proxy.Breaker.now = func() time.Time { return now }
+
+	h := NewHandler([]*Route{route}, mockMetricPublisher)
+	_, e := gin.CreateTestContext(nil)
+	e.NoRoute(h.SelectRoute, LogAndMetricsMiddleware(mockMetricPublisher), h.ForwardRequest)
+
+	serve := func() *test.TestResponseRecorder {
+		w := test.NewTestResponseRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/payments/1", nil)
+		require.NoError(t, err)
+		e.ServeHTTP(w, req)
+		return w
+	}
+
+	// The failure opens the breaker
+	require.Equal(t, http.StatusServiceUnavailable, serve().Code)
+	upstreamStatus = http.StatusOK
+	now = now.Add(time.Minute)
+
+	// The request failing before being forwarded does not take the probe slot of the half-open breaker
+	require.Equal(t, http.StatusBadRequest, serve().Code)
+	require.Equal(t, http.StatusOK, serve().Code)
+}
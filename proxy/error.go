@@ -20,3 +20,67 @@ func (e *InvalidRequestError) Error() string {
 func (e *InvalidRequestError) Unwrap() error {
 	return e.reason
 }
+
+// UnauthorizedError is raised when the credentials of the client are missing or invalid.
+type UnauthorizedError struct {
+	reason error
+}
+
+func NewUnauthorizedError(reason error) error {
+	return &UnauthorizedError{
+		reason: reason,
+	}
+}
+
+func (e *UnauthorizedError) Error() string {
+	return fmt.Sprintf("unauthorized: %s", e.reason.Error())
+}
+
+func (e *UnauthorizedError) Unwrap() error {
+	return e.reason
+}
+
+// ForbiddenError is raised when the client is not allowed to have the request signed.
+type ForbiddenError struct {
+	reason error
+}
+
+func NewForbiddenError(reason error) error {
+	return &ForbiddenError{
+		reason: reason,
+	}
+}
+
+func (e *ForbiddenError) Error() string {
+	return fmt.Sprintf("forbidden: %s", e.reason.Error())
+}
+
+func (e *ForbiddenError) Unwrap() error {
+	return e.reason
+}
+
+// UpstreamError is raised when the request cannot be forwarded to the upstream target, or its response cannot be read.
+type UpstreamError struct {
+	errType string
+	reason  error
+}
+
+func NewUpstreamError(errType string, reason error) error {
+	return &UpstreamError{
+		errType: errType,
+		reason:  reason,
+	}
+}
+
+// Type returns the type of the error, one of the UpstreamError... constants.
+func (e *UpstreamError) Type() string {
+	return e.errType
+}
+
+func (e *UpstreamError) Error() string {
+	return fmt.Sprintf("upstream %s error: %s", e.errType, e.reason.Error())
+}
+
+func (e *UpstreamError) Unwrap() error {
+	return e.reason
+}
@@ -0,0 +1,121 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+)
+
+// RequestFilter restricts the requests that are signed to the ones allowed by its rules.
+type RequestFilter struct {
+	allow []*filterRule
+	deny  []*filterRule
+}
+
+type filterRule struct {
+	methods []string
+	path    *regexp.Regexp
+	// pattern is the path pattern of the config, reported when the rule denies a request
+	pattern string
+}
+
+// NewRequestFilter compiles the path patterns of the rules of cfg.
+func NewRequestFilter(cfg config.FilterConfig) (*RequestFilter, error) {
+	f := &RequestFilter{}
+	for _, ruleCfg := range cfg.Allow {
+		rule, err := newFilterRule(ruleCfg)
+		if err != nil {
+			return nil, err
+		}
+		f.allow = append(f.allow, rule)
+	}
+	for _, ruleCfg := range cfg.Deny {
+		rule, err := newFilterRule(ruleCfg)
+		if err != nil {
+			return nil, err
+		}
+		f.deny = append(f.deny, rule)
+	}
+	return f, nil
+}
+
+func newFilterRule(cfg config.FilterRuleConfig) (*filterRule, error) {
+	pattern := cfg.PathRegex
+	expr := "^(?:" + cfg.PathRegex + ")$"
+	if cfg.PathRegex == "" {
+		pattern = cfg.Path
+		expr = globToRegex(cfg.Path)
+	}
+	path, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path pattern '%s': %w", pattern, err)
+	}
+	return &filterRule{
+		methods: cfg.Methods,
+		path:    path,
+		pattern: pattern,
+	}, nil
+}
+
+// globToRegex converts a glob pattern to a regular expression matching the whole path,
+// where * matches any characters but / and ** matches any characters.
+func globToRegex(glob string) string {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			sb.WriteString("[^/]*")
+		default:
+			sb.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String()
+}
+
+// matches reports whether the method and the normalized path of a request satisfy the rule.
+func (r *filterRule) matches(method string, path string) bool {
+	return r.path.MatchString(path) && r.matchesMethod(method)
+}
+
+func (r *filterRule) matchesMethod(method string) bool {
+	if len(r.methods) == 0 {
+		return true
+	}
+	for _, m := range r.methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns a ForbiddenError giving the reason if the request is not allowed, nil otherwise. Rules are matched
+// against the normalized path of the request, a path that cannot be normalized is rejected with an InvalidRequestError.
+func (f *RequestFilter) Check(req *http.Request) error {
+	path, err := NormalizePath(req)
+	if err != nil {
+		return err
+	}
+	for _, rule := range f.deny {
+		if rule.matches(req.Method, path) {
+			return NewForbiddenError(fmt.Errorf("%s %s is denied by rule '%s'", req.Method, path, rule.pattern))
+		}
+	}
+	if len(f.allow) == 0 {
+		return nil
+	}
+	for _, rule := range f.allow {
+		if rule.matches(req.Method, path) {
+			return nil
+		}
+	}
+	return NewForbiddenError(fmt.Errorf("%s %s is not allowed by any rule", req.Method, path))
+}
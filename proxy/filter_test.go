@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestFilter(t *testing.T) {
+	filter, err := NewRequestFilter(config.FilterConfig{
+		Allow: []config.FilterRuleConfig{
+			{Methods: []string{"POST"}, Path: "/v1/transaction/payments"},
+			{Methods: []string{"get"}, Path: "/v1/organisation/*"},
+			{Path: "/v1/files/**"},
+			{Methods: []string{"GET"}, PathRegex: "/v1/accounts/[0-9a-f-]+"},
+		},
+		Deny: []config.FilterRuleConfig{
+			{Path: "/v1/files/**/secret"},
+		},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		method         string
+		path           string
+		expectedReason string
+	}{
+		{http.MethodPost, "/v1/transaction/payments", ""},
+		{http.MethodGet, "/v1/transaction/payments", "forbidden: GET /v1/transaction/payments is not allowed by any rule"},
+		{http.MethodGet, "/v1/organisation/units", ""},
+		{http.MethodGet, "/v1/organisation/units/1", "forbidden: GET /v1/organisation/units/1 is not allowed by any rule"},
+		{http.MethodDelete, "/v1/files/a/b", ""},
+		{http.MethodGet, "/v1/files/a/secret", "forbidden: GET /v1/files/a/secret is denied by rule '/v1/files/**/secret'"},
+		{http.MethodGet, "/v1/accounts/0a6ac3d5-8f0b", ""},
+		{http.MethodGet, "/v1/accounts/0a6ac3d5/x", "forbidden: GET /v1/accounts/0a6ac3d5/x is not allowed by any rule"},
+		{http.MethodGet, "/v2/transaction.payments", "forbidden: GET /v2/transaction.payments is not allowed by any rule"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, "http://localhost:8080"+tt.path, nil)
+			require.NoError(t, err)
+
+			err = filter.Check(req)
+			if tt.expectedReason == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.expectedReason)
+		})
+	}
+}
+
+func TestRequestFilterWithoutAllowRules(t *testing.T) {
+	// Requests are allowed unless denied
+	filter, err := NewRequestFilter(config.FilterConfig{
+		Deny: []config.FilterRuleConfig{{Methods: []string{"DELETE"}, Path: "/**"}},
+	})
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/v1/payments", nil)
+	require.NoError(t, err)
+	require.NoError(t, filter.Check(req))
+	req.Method = http.MethodDelete
+	require.Error(t, filter.Check(req))
+}
+
+func TestRequestFilterTraversal(t *testing.T) {
+	filter, err := NewRequestFilter(config.FilterConfig{
+		Allow: []config.FilterRuleConfig{{Path: "/v1/organisation/**"}},
+		Deny:  []config.FilterRuleConfig{{Path: "/v1/organisation/admin/**"}},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedReason string
+	}{
+		{"dot dot segment", "/v1/organisation/../admin", "invalid request: dot segments are not allowed in the path"},
+		{"encoded dot dot segment", "/v1/organisation/%2e%2e/admin", "invalid request: dot segments are not allowed in the path"},
+		{"dot segment", "/v1/organisation/./units", "invalid request: dot segments are not allowed in the path"},
+		{"encoded slash", "/v1/organisation%2Fadmin/users", "invalid request: encoded slashes are not allowed in the path"},
+		{"duplicate slashes", "/v1//organisation///admin/users", "forbidden: GET /v1/organisation/admin/users is denied by rule '/v1/organisation/admin/**'"},
+		{"duplicate slashes allowed", "//v1/organisation//units", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "http://localhost:8080"+tt.path, nil)
+			require.NoError(t, err)
+
+			err = filter.Check(req)
+			if tt.expectedReason == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.expectedReason)
+		})
+	}
+}
@@ -1,7 +1,11 @@
 package proxy
 
 import (
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -9,31 +13,98 @@ import (
 
 type Handler interface {
 	Health(c *gin.Context)
+	SelectRoute(c *gin.Context)
 	ForwardRequest(c *gin.Context)
+	SetRoutes(routes []*Route)
 }
 
 type handler struct {
-	proxy           *ReverseProxy
-	reqSigner       RequestSigner
+	// routes holds a []*Route, replaced as a whole when the config is reloaded
+	routes          atomic.Value
 	metricPublisher MetricPublisher
 }
 
-func NewHandler(proxy *ReverseProxy, reqSigner RequestSigner, metricPublisher MetricPublisher) Handler {
-	return &handler{
-		proxy:           proxy,
-		reqSigner:       reqSigner,
+func NewHandler(routes []*Route, metricPublisher MetricPublisher) Handler {
+	h := &handler{
 		metricPublisher: metricPublisher,
 	}
+	h.routes.Store(routes)
+	return h
 }
 
 func (h *handler) Health(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "up"})
 }
 
+// SelectRoute stores the first route matching the request in the context, so that it is available to
+// the following middlewares and ForwardRequest. Requests matching no route are rejected by ForwardRequest.
+// The route is held until the request is done, so that it is not closed while in use.
+func (h *handler) SelectRoute(c *gin.Context) {
+	routes := h.routes.Load().([]*Route)
+	for {
+		route := matchRoute(routes, c.Request)
+		if route == nil {
+			return
+		}
+		if route.acquire() {
+			defer route.release()
+			c.Set(routeContextKey, route)
+			c.Next()
+			return
+		}
+		// The route was retired by a reload in the meantime, the routes replacing it are matched instead
+		current := h.routes.Load().([]*Route)
+		if sameRoutes(current, routes) {
+			return
+		}
+		routes = current
+	}
+}
+
+func matchRoute(routes []*Route, req *http.Request) *Route {
+	for _, route := range routes {
+		if route.Matches(req) {
+			return route
+		}
+	}
+	return nil
+}
+
+func sameRoutes(a []*Route, b []*Route) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// SetRoutes replaces the routes used for the following requests, requests in progress keep the route they selected.
+// Replaced routes are to be retired once the new routes are set.
+func (h *handler) SetRoutes(routes []*Route) {
+	h.routes.Store(routes)
+}
+
 func (h *handler) ForwardRequest(c *gin.Context) {
-	req := c.Request.Clone(c)
-	req.Host = h.proxy.TargetHost
-	req.Header.Set("Host", h.proxy.TargetHost)
+	// Paths that may resolve upstream to another path than the one the route was selected with are not forwarded
+	if _, err := NormalizePath(c.Request); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	route := getRoute(c)
+	if route == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "no route matches the request"})
+		return
+	}
+
+	// The context of the request is kept, so that the upstream request is cancelled when the client goes away
+	req := c.Request.Clone(c.Request.Context())
+	// Point the request to the upstream target before signing, so that signatures covering
+	// the target URI match what the upstream receives
+	route.Proxy.Rewrite(req)
 
 	// Add Date header since some clients don't automatically add it
 	date := req.Header.Get("Date")
@@ -41,8 +112,18 @@ func (h *handler) ForwardRequest(c *gin.Context) {
 		req.Header.Set("Date", time.Now().Format(http.TimeFormat))
 	}
 
+	// The body of requests that may be retried is buffered, so that it can be sent again
+	if route.canRetry(req) {
+		var err error
+		req, err = withRetry(req, route.retry.MaxBodySize)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	start := time.Now()
-	signedReq, err := h.reqSigner.SignRequest(req)
+	signedReq, err := route.ReqSigner.SignRequest(req)
 	singingDuration := time.Since(start)
 
 	if err != nil {
@@ -51,13 +132,25 @@ func (h *handler) ForwardRequest(c *gin.Context) {
 		case *InvalidRequestError:
 			c.AbortWithStatusJSON(http.StatusBadRequest, errJson)
 		default:
-			h.metricPublisher.IncrementInternalErrorCount(c.Request.Method, c.Request.URL.Path)
+			h.metricPublisher.IncrementInternalErrorCount(route.Name, c.Request.Method, c.Request.URL.Path)
 			c.AbortWithStatusJSON(http.StatusInternalServerError, errJson)
 		}
 		return
 	}
 
-	h.metricPublisher.MeasureSigningDuration(c.Request.Method, c.Request.URL.Path, singingDuration.Seconds())
-	h.metricPublisher.IncrementSignedRequestCount(c.Request.Method, c.Request.URL.Path)
-	h.proxy.ServeHTTP(c.Writer, signedReq)
+	// The breaker is only asked once the request is ready to be forwarded, so that a half-open breaker does not let
+	// through a probe which fails before reaching the upstream target and never has its outcome recorded
+	if breaker := route.Proxy.Breaker; breaker != nil {
+		if ok, wait := breaker.Allow(); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"error": fmt.Sprintf("circuit breaker of route '%s' is open, the upstream target is unavailable", route.Name),
+			})
+			return
+		}
+	}
+
+	h.metricPublisher.MeasureSigningDuration(route.Name, c.Request.Method, c.Request.URL.Path, singingDuration.Seconds())
+	h.metricPublisher.IncrementSignedRequestCount(route.Name, c.Request.Method, c.Request.URL.Path)
+	route.Proxy.ServeHTTP(c.Writer, signedReq)
 }
@@ -1,11 +1,17 @@
 package proxy
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
 	"github.com/form3tech-oss/http-message-signing-proxy/test"
 	"github.com/gin-gonic/gin"
 	"github.com/golang/mock/gomock"
@@ -44,21 +50,21 @@ func TestHandler(t *testing.T) {
 
 	// Mock dependencies
 	mockReqSigner := mockReqSigner(mockCtrl)
-	mockMetricPublisher := mockMetricPublisher(mockCtrl, mockURL)
+	mockMetricPublisher := mockMetricPublisher(mockCtrl, "test", http.MethodGet, mockURL)
 
 	// Test upstream target that returns 200 OK
 	targetSrv := testTargetServer(expectedRespBody)
 
-	// Reverse proxy pointing to test target
-	rs, err := NewReverseProxy(targetSrv.URL)
-	require.NoError(t, err)
+	// Route pointing to test target
+	route := testRoute(t, config.MatchConfig{}, targetSrv.URL, mockReqSigner, mockMetricPublisher)
 
 	// Test handler
 	var w *test.TestResponseRecorder
-	h := NewHandler(rs, mockReqSigner, mockMetricPublisher)
+	h := NewHandler([]*Route{route}, mockMetricPublisher)
 	_, e := gin.CreateTestContext(w)
 	e.NoRoute(
 		RecoverMiddleware(mockMetricPublisher),
+		h.SelectRoute,
 		LogAndMetricsMiddleware(mockMetricPublisher),
 		h.ForwardRequest,
 	)
@@ -110,23 +116,23 @@ func TestHandlerCORS(t *testing.T) {
 
 	// Mock dependencies
 	mockReqSigner := mockReqSigner(mockCtrl)
-	mockMetricPublisher := mockMetricPublisher(mockCtrl, mockURL)
+	mockMetricPublisher := mockMetricPublisher(mockCtrl, "test", http.MethodGet, mockURL)
 
 	// Test upstream target that returns 200 OK
 	targetSrv := testTargetServer(expectedRespBody)
 
-	// Reverse proxy pointing to test target
-	rs, err := NewReverseProxy(targetSrv.URL)
-	require.NoError(t, err)
+	// Route pointing to test target
+	route := testRoute(t, config.MatchConfig{}, targetSrv.URL, mockReqSigner, mockMetricPublisher)
 
 	// Test handler
 	var w *test.TestResponseRecorder
 
 	for _, tt := range tests {
-		h := NewHandler(rs, mockReqSigner, mockMetricPublisher)
+		h := NewHandler([]*Route{route}, mockMetricPublisher)
 		_, e := gin.CreateTestContext(w)
 		e.NoRoute(
 			RecoverMiddleware(mockMetricPublisher),
+			h.SelectRoute,
 			LogAndMetricsMiddleware(mockMetricPublisher),
 			CORSMiddleware(tt.accessControlAllowOrigin),
 			h.ForwardRequest,
@@ -147,6 +153,517 @@ func TestHandlerCORS(t *testing.T) {
 	}
 }
 
+func TestHandlerUpstreamPath(t *testing.T) {
+	tests := []struct {
+		name           string
+		upstreamPath   string
+		path           string
+		expectedTarget string
+	}{
+		{"no upstream path", "", "/payments/1?page=2", "/payments/1?page=2"},
+		{"upstream base path", "/base", "/payments/1?page=2", "/base/payments/1?page=2"},
+		{"upstream base path with trailing slash", "/base/", "/payments/1", "/base/payments/1"},
+		{"upstream query", "/base?tenant=a", "/payments/1?page=2", "/base/payments/1?tenant=a&page=2"},
+		{"escaped request path", "/base", "/payments/a%20b", "/base/payments/a%20b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.path, nil)
+			require.NoError(t, err)
+
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockMetricPublisher := mockMetricPublisher(mockCtrl, "test", http.MethodGet, req.URL.Path)
+
+			// Test upstream target echoing the URL it receives and the URL it was signed with
+			targetSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = fmt.Fprintf(w, "%s %s", r.URL.RequestURI(), r.Header.Get("X-Signed-Url"))
+			}))
+			defer targetSrv.Close()
+
+			route := testRoute(t, config.MatchConfig{}, targetSrv.URL+tt.upstreamPath, &countingSigner{}, mockMetricPublisher)
+			h := NewHandler([]*Route{route}, mockMetricPublisher)
+			_, e := gin.CreateTestContext(nil)
+			e.NoRoute(h.SelectRoute, LogAndMetricsMiddleware(mockMetricPublisher), h.ForwardRequest)
+
+			w := test.NewTestResponseRecorder()
+			e.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			require.Equal(t, fmt.Sprintf("%s %s%s", tt.expectedTarget, targetSrv.URL, tt.expectedTarget), w.Body.String())
+		})
+	}
+}
+
+func TestHandlerRouting(t *testing.T) {
+	tests := []struct {
+		name             string
+		path             string
+		headers          map[string]string
+		expectedStatus   int
+		expectedRespBody string
+	}{
+		{
+			"path prefix route",
+			"/payments/1",
+			nil,
+			http.StatusOK,
+			"payments",
+		},
+		{
+			"header route",
+			"/accounts/1",
+			map[string]string{
+				"X-Counterparty": "bank",
+			},
+			http.StatusOK,
+			"bank",
+		},
+		{
+			"first matching route wins",
+			"/payments/1",
+			map[string]string{
+				"X-Counterparty": "bank",
+			},
+			http.StatusOK,
+			"payments",
+		},
+		{
+			"no matching route",
+			"/accounts/1",
+			nil,
+			http.StatusNotFound,
+			`{"error":"no route matches the request"}`,
+		},
+		{
+			"path prefix matching whole segments",
+			"/payments-admin",
+			map[string]string{
+				"X-Counterparty": "bank",
+			},
+			http.StatusOK,
+			"bank",
+		},
+		{
+			"duplicate slashes",
+			"http://localhost//payments/1",
+			map[string]string{
+				"X-Counterparty": "bank",
+			},
+			http.StatusOK,
+			"payments",
+		},
+		{
+			"dot segments",
+			"/payments/../admin",
+			map[string]string{
+				"X-Counterparty": "bank",
+			},
+			http.StatusBadRequest,
+			`{"error":"invalid request: dot segments are not allowed in the path"}`,
+		},
+	}
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// Mock dependencies
+	mockReqSigner := mockReqSigner(mockCtrl)
+	mockMetricPublisher := mockMetricPublisher(mockCtrl, gomock.Any(), http.MethodGet, gomock.Any())
+
+	// Routes pointing to test targets that return different bodies
+	routes := []*Route{
+		testRoute(t, config.MatchConfig{PathPrefix: "/payments"}, testTargetServer("payments").URL, mockReqSigner, mockMetricPublisher),
+		testRoute(t, config.MatchConfig{Header: config.HeaderMatchConfig{Name: "X-Counterparty", Value: "bank"}}, testTargetServer("bank").URL, mockReqSigner, mockMetricPublisher),
+	}
+
+	// Test handler
+	h := NewHandler(routes, mockMetricPublisher)
+	_, e := gin.CreateTestContext(nil)
+	e.NoRoute(
+		RecoverMiddleware(mockMetricPublisher),
+		h.SelectRoute,
+		LogAndMetricsMiddleware(mockMetricPublisher),
+		h.ForwardRequest,
+	)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := test.NewTestResponseRecorder()
+
+			req, err := http.NewRequest(http.MethodGet, tt.path, nil)
+			require.NoError(t, err)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			e.ServeHTTP(w, req)
+
+			require.Equal(t, tt.expectedStatus, w.Code)
+			require.Equal(t, tt.expectedRespBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandlerSetRoutes(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// Mock dependencies
+	mockReqSigner := mockReqSigner(mockCtrl)
+	mockMetricPublisher := mockMetricPublisher(mockCtrl, gomock.Any(), http.MethodGet, gomock.Any())
+
+	// Test handler without routes
+	h := NewHandler(nil, mockMetricPublisher)
+	_, e := gin.CreateTestContext(nil)
+	e.NoRoute(
+		RecoverMiddleware(mockMetricPublisher),
+		h.SelectRoute,
+		LogAndMetricsMiddleware(mockMetricPublisher),
+		h.ForwardRequest,
+	)
+
+	serve := func() *test.TestResponseRecorder {
+		w := test.NewTestResponseRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/payments/1", nil)
+		require.NoError(t, err)
+		e.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve()
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	h.SetRoutes([]*Route{testRoute(t, config.MatchConfig{}, testTargetServer("first").URL, mockReqSigner, mockMetricPublisher)})
+	w = serve()
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "first", w.Body.String())
+
+	h.SetRoutes([]*Route{testRoute(t, config.MatchConfig{}, testTargetServer("second").URL, mockReqSigner, mockMetricPublisher)})
+	w = serve()
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "second", w.Body.String())
+}
+
+func TestHandlerRetireRoute(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// Mock dependencies
+	mockReqSigner := mockReqSigner(mockCtrl)
+	mockMetricPublisher := mockMetricPublisher(mockCtrl, gomock.Any(), http.MethodGet, "/payments/1")
+
+	// Test upstream target holding the requests until released
+	received := make(chan struct{})
+	release := make(chan struct{})
+	slowSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(received)
+		<-release
+		_, _ = w.Write([]byte("first"))
+	}))
+	defer slowSrv.Close()
+	first := testRoute(t, config.MatchConfig{}, slowSrv.URL, mockReqSigner, mockMetricPublisher)
+
+	// Test handler
+	h := NewHandler([]*Route{first}, mockMetricPublisher)
+	_, e := gin.CreateTestContext(nil)
+	e.NoRoute(h.SelectRoute, LogAndMetricsMiddleware(mockMetricPublisher), h.ForwardRequest)
+
+	serve := func() *test.TestResponseRecorder {
+		w := test.NewTestResponseRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/payments/1", nil)
+		require.NoError(t, err)
+		e.ServeHTTP(w, req)
+		return w
+	}
+
+	inFlight := make(chan *test.TestResponseRecorder)
+	go func() {
+		inFlight <- serve()
+	}()
+	<-received
+
+	// The replaced route is drained once the request in progress is done, new requests use the new route
+	h.SetRoutes([]*Route{testRoute(t, config.MatchConfig{}, testTargetServer("second").URL, mockReqSigner, mockMetricPublisher)})
+	drained := first.Retire()
+	require.Equal(t, "second", serve().Body.String())
+	select {
+	case <-drained:
+		require.Fail(t, "route drained while a request is in progress")
+	default:
+	}
+
+	close(release)
+	require.Equal(t, "first", (<-inFlight).Body.String())
+	<-drained
+
+	// A retired route is not used anymore, even if still set
+	h.SetRoutes([]*Route{first})
+	require.Equal(t, http.StatusNotFound, serve().Code)
+}
+
+type testAuthenticator map[string]string
+
+func (a testAuthenticator) Authenticate(req *http.Request) (string, error) {
+	client, ok := a[req.Header.Get("X-API-Key")]
+	if !ok {
+		return "", NewUnauthorizedError(errors.New("invalid API key"))
+	}
+	return client, nil
+}
+
+func (a testAuthenticator) StripCredentials(req *http.Request) {
+	req.Header.Del("X-API-Key")
+}
+
+type testAuthorizer map[string]string
+
+func (a testAuthorizer) Authorize(client string, req *http.Request) error {
+	if a[client] != req.Method {
+		return NewForbiddenError(fmt.Errorf("client '%s' is not allowed to %s", client, req.Method))
+	}
+	return nil
+}
+
+func TestHandlerAuth(t *testing.T) {
+	tests := []struct {
+		name             string
+		method           string
+		apiKey           string
+		expectedStatus   int
+		expectedRespBody string
+	}{
+		{
+			"allowed client",
+			http.MethodGet,
+			"reports-key",
+			http.StatusOK,
+			"OK",
+		},
+		{
+			"unknown client",
+			http.MethodGet,
+			"unknown-key",
+			http.StatusUnauthorized,
+			`{"error":"unauthorized: invalid API key"}`,
+		},
+		{
+			"forbidden method",
+			http.MethodPost,
+			"reports-key",
+			http.StatusForbidden,
+			`{"error":"forbidden: client 'reports' is not allowed to POST"}`,
+		},
+	}
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// Mock dependencies
+	mockReqSigner := mockReqSigner(mockCtrl)
+	mockMetricPublisher := mockMetricPublisher(mockCtrl, gomock.Any(), gomock.Any(), gomock.Any())
+	// Only the authenticated clients are counted
+	mockMetricPublisher.EXPECT().IncrementClientRequestCount("test", "reports").Times(2)
+
+	// Test handler
+	h := NewHandler([]*Route{testRoute(t, config.MatchConfig{}, testTargetServer("OK").URL, mockReqSigner, mockMetricPublisher)}, mockMetricPublisher)
+	_, e := gin.CreateTestContext(nil)
+	e.NoRoute(
+		RecoverMiddleware(mockMetricPublisher),
+		h.SelectRoute,
+		LogAndMetricsMiddleware(mockMetricPublisher),
+		AuthMiddleware(testAuthenticator{"reports-key": "reports"}, testAuthorizer{"reports": http.MethodGet}),
+		h.ForwardRequest,
+	)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := test.NewTestResponseRecorder()
+
+			req, err := http.NewRequest(tt.method, "/payments/1", nil)
+			require.NoError(t, err)
+			req.Header.Set("X-API-Key", tt.apiKey)
+
+			e.ServeHTTP(w, req)
+
+			require.Equal(t, tt.expectedStatus, w.Code)
+			require.Equal(t, tt.expectedRespBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandlerAuthClientCert(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// Mock dependencies
+	mockReqSigner := mockReqSigner(mockCtrl)
+	mockMetricPublisher := mockMetricPublisher(mockCtrl, gomock.Any(), gomock.Any(), gomock.Any())
+	mockMetricPublisher.EXPECT().IncrementClientRequestCount("test", "billing")
+
+	// The upstream target answers with the API key it received
+	targetServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(r.Header.Get("X-API-Key")))
+	}))
+	defer targetServer.Close()
+
+	// Test handler
+	h := NewHandler([]*Route{testRoute(t, config.MatchConfig{}, targetServer.URL, mockReqSigner, mockMetricPublisher)}, mockMetricPublisher)
+	_, e := gin.CreateTestContext(nil)
+	e.NoRoute(
+		RecoverMiddleware(mockMetricPublisher),
+		h.SelectRoute,
+		ClientCertMiddleware(),
+		LogAndMetricsMiddleware(mockMetricPublisher),
+		AuthMiddleware(testAuthenticator{"reports-key": "reports"}, testAuthorizer{"billing": http.MethodGet}),
+		h.ForwardRequest,
+	)
+
+	w := test.NewTestResponseRecorder()
+	req, err := http.NewRequest(http.MethodGet, "/payments/1", nil)
+	require.NoError(t, err)
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "billing"}}}}
+	// The client identified by its certificate also sends an API key, which must not be forwarded
+	req.Header.Set("X-API-Key", "reports-key")
+
+	e.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Empty(t, w.Body.String())
+}
+
+func TestHandlerFilter(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// Mock dependencies
+	mockReqSigner := mockReqSigner(mockCtrl)
+	mockMetricPublisher := mockMetricPublisher(mockCtrl, gomock.Any(), gomock.Any(), gomock.Any())
+	// Denied requests are counted
+	mockMetricPublisher.EXPECT().IncrementDeniedRequestCount("test", http.MethodPost, "/payments/1").Times(1)
+
+	filter, err := NewRequestFilter(config.FilterConfig{
+		Allow: []config.FilterRuleConfig{{Methods: []string{"GET"}, Path: "/payments/*"}},
+	})
+	require.NoError(t, err)
+
+	// Test handler
+	h := NewHandler([]*Route{testRoute(t, config.MatchConfig{}, testTargetServer("OK").URL, mockReqSigner, mockMetricPublisher)}, mockMetricPublisher)
+	_, e := gin.CreateTestContext(nil)
+	e.NoRoute(
+		RecoverMiddleware(mockMetricPublisher),
+		h.SelectRoute,
+		LogAndMetricsMiddleware(mockMetricPublisher),
+		FilterMiddleware(filter, mockMetricPublisher),
+		h.ForwardRequest,
+	)
+
+	serve := func(method string) *test.TestResponseRecorder {
+		w := test.NewTestResponseRecorder()
+		req, err := http.NewRequest(method, "/payments/1", nil)
+		require.NoError(t, err)
+		e.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(http.MethodGet)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// Denied requests are not forwarded
+	w = serve(http.MethodPost)
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.Equal(t, `{"error":"forbidden: POST /payments/1 is not allowed by any rule"}`, w.Body.String())
+}
+
+func TestHandlerRateLimit(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// Mock dependencies
+	mockReqSigner := mockReqSigner(mockCtrl)
+	mockMetricPublisher := mockMetricPublisher(mockCtrl, "test", http.MethodGet, "/payments/1")
+	// Rate limited requests are counted
+	mockMetricPublisher.EXPECT().IncrementRateLimitedRequestCount("test", http.MethodGet, "/payments/1", GlobalLimit).Times(1)
+
+	limiter, err := NewRateLimiter(config.RateLimitConfig{
+		Global: config.LimitConfig{Rate: 0.1},
+	})
+	require.NoError(t, err)
+
+	// Test handler
+	h := NewHandler([]*Route{testRoute(t, config.MatchConfig{}, testTargetServer("OK").URL, mockReqSigner, mockMetricPublisher)}, mockMetricPublisher)
+	_, e := gin.CreateTestContext(nil)
+	e.NoRoute(
+		RecoverMiddleware(mockMetricPublisher),
+		h.SelectRoute,
+		LogAndMetricsMiddleware(mockMetricPublisher),
+		RateLimitMiddleware(limiter, mockMetricPublisher),
+		h.ForwardRequest,
+	)
+
+	serve := func() *test.TestResponseRecorder {
+		w := test.NewTestResponseRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/payments/1", nil)
+		require.NoError(t, err)
+		e.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve()
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// The bucket holds a single token, refilled in 10s
+	w = serve()
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "10", w.Header().Get("Retry-After"))
+	require.Equal(t, `{"error":"global rate limit exceeded"}`, w.Body.String())
+}
+
+func TestHandlerUpstreamMetrics(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// Mock dependencies
+	mockReqSigner := mockReqSigner(mockCtrl)
+	mockMetricPublisher := NewMockMetricPublisher(mockCtrl)
+	mockMetricPublisher.EXPECT().IncrementTotalRequestCount("test", http.MethodGet, "/payments/1").Times(1)
+	mockMetricPublisher.EXPECT().MeasureSigningDuration("test", http.MethodGet, "/payments/1", gomock.Any()).Times(1)
+	mockMetricPublisher.EXPECT().IncrementSignedRequestCount("test", http.MethodGet, "/payments/1").Times(1)
+	// Signature rejections by the upstream target are told apart from upstream failures by their status
+	mockMetricPublisher.EXPECT().MeasureTotalDuration("test", http.MethodGet, "/payments/1", http.StatusUnauthorized, gomock.Any()).Times(1)
+	mockMetricPublisher.EXPECT().MeasureUpstreamDuration("test", http.MethodGet, "4xx", gomock.Any()).Times(1)
+	mockMetricPublisher.EXPECT().IncrementUpstreamResponseCount("test", http.MethodGet, "4xx").Times(1)
+
+	// Test upstream target rejecting the signature
+	targetSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer targetSrv.Close()
+
+	// Test handler
+	h := NewHandler([]*Route{testRoute(t, config.MatchConfig{}, targetSrv.URL, mockReqSigner, mockMetricPublisher)}, mockMetricPublisher)
+	_, e := gin.CreateTestContext(nil)
+	e.NoRoute(h.SelectRoute, LogAndMetricsMiddleware(mockMetricPublisher), h.ForwardRequest)
+
+	w := test.NewTestResponseRecorder()
+	req, err := http.NewRequest(http.MethodGet, "/payments/1", nil)
+	require.NoError(t, err)
+	e.ServeHTTP(w, req)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func testRoute(t *testing.T, match config.MatchConfig, upstreamTarget string, reqSigner RequestSigner, metricPublisher MetricPublisher) *Route {
+	route, err := NewRoute(config.RouteConfig{
+		Name:           "test",
+		Match:          match,
+		UpstreamTarget: upstreamTarget,
+	}, reqSigner, metricPublisher)
+	require.NoError(t, err)
+	return route
+}
+
 func mockReqSigner(mockCtrl *gomock.Controller) *MockRequestSigner {
 	mockReqSigner := NewMockRequestSigner(mockCtrl)
 	mockReqSigner.EXPECT().SignRequest(gomock.Any()).DoAndReturn(func(r *http.Request) (*http.Request, error) {
@@ -156,12 +673,16 @@ func mockReqSigner(mockCtrl *gomock.Controller) *MockRequestSigner {
 	return mockReqSigner
 }
 
-func mockMetricPublisher(mockCtrl *gomock.Controller, mockURL string) *MockMetricPublisher {
+// mockMetricPublisher returns a metric publisher accepting the metrics of any number of requests with the given route,
+// method and path. Each of them can be a gomock matcher, e.g. gomock.Any().
+func mockMetricPublisher(mockCtrl *gomock.Controller, route, method, path interface{}) *MockMetricPublisher {
 	mockMetricPublisher := NewMockMetricPublisher(mockCtrl)
-	mockMetricPublisher.EXPECT().IncrementTotalRequestCount(http.MethodGet, mockURL).AnyTimes()
-	mockMetricPublisher.EXPECT().MeasureSigningDuration(http.MethodGet, mockURL, gomock.Any()).AnyTimes()
-	mockMetricPublisher.EXPECT().IncrementSignedRequestCount(http.MethodGet, mockURL).AnyTimes()
-	mockMetricPublisher.EXPECT().MeasureTotalDuration(http.MethodGet, mockURL, gomock.Any()).AnyTimes()
+	mockMetricPublisher.EXPECT().IncrementTotalRequestCount(route, method, path).AnyTimes()
+	mockMetricPublisher.EXPECT().MeasureSigningDuration(route, method, path, gomock.Any()).AnyTimes()
+	mockMetricPublisher.EXPECT().IncrementSignedRequestCount(route, method, path).AnyTimes()
+	mockMetricPublisher.EXPECT().MeasureTotalDuration(route, method, path, gomock.Any(), gomock.Any()).AnyTimes()
+	mockMetricPublisher.EXPECT().MeasureUpstreamDuration(route, method, gomock.Any(), gomock.Any()).AnyTimes()
+	mockMetricPublisher.EXPECT().IncrementUpstreamResponseCount(route, method, gomock.Any()).AnyTimes()
 	return mockMetricPublisher
 }
 
@@ -1,9 +1,19 @@
 package proxy
 
 type MetricPublisher interface {
-	IncrementTotalRequestCount(method string, path string)
-	IncrementSignedRequestCount(method string, path string)
-	IncrementInternalErrorCount(method string, path string)
-	MeasureSigningDuration(method string, path string, duration float64)
-	MeasureTotalDuration(method string, path string, duration float64)
+	IncrementTotalRequestCount(route string, method string, path string)
+	IncrementSignedRequestCount(route string, method string, path string)
+	IncrementInternalErrorCount(route string, method string, path string)
+	MeasureSigningDuration(route string, method string, path string, duration float64)
+	MeasureTotalDuration(route string, method string, path string, statusCode int, duration float64)
+	MeasureUpstreamDuration(route string, method string, statusClass string, duration float64)
+	IncrementUpstreamResponseCount(route string, method string, statusClass string)
+	IncrementClientRequestCount(route string, client string)
+	IncrementDeniedRequestCount(route string, method string, path string)
+	IncrementRateLimitedRequestCount(route string, method string, path string, limit string)
+	SetCircuitBreakerState(route string, state CircuitState)
+	IncrementUpstreamErrorCount(route string, errType string)
+	IncrementKeyReloadErrorCount(route string, keyId string)
+	SetActiveKey(route string, keyId string, active bool)
+	SetSecondsUntilKeyRotation(route string, keyId string, seconds float64)
 }
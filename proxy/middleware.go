@@ -2,10 +2,13 @@ package proxy
 
 import (
 	"errors"
+	"fmt"
+	"math"
 	"net"
 	"net/http"
 	"os"
 	"runtime/debug"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,6 +18,8 @@ import (
 
 const (
 	AccessControlAllowOriginHeader string = "Access-Control-Allow-Origin"
+
+	clientContextKey = "signing_proxy_client"
 )
 
 func RecoverMiddleware(metricPublisher MetricPublisher) gin.HandlerFunc {
@@ -25,7 +30,7 @@ func RecoverMiddleware(metricPublisher MetricPublisher) gin.HandlerFunc {
 					"error":       err,
 					"stack_trace": string(debug.Stack()),
 				}).Errorf("uncaught panic")
-				metricPublisher.IncrementInternalErrorCount(c.Request.Method, c.Request.URL.Path)
+				metricPublisher.IncrementInternalErrorCount(getRouteName(c), c.Request.Method, c.Request.URL.Path)
 
 				// Check for a broken connection
 				var brokenPipe bool
@@ -50,15 +55,35 @@ func RecoverMiddleware(metricPublisher MetricPublisher) gin.HandlerFunc {
 	}
 }
 
+// ClientCertMiddleware stores the identity of the client certificate verified by the server in the context,
+// so that it is available to the following middlewares.
+func ClientCertMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.TLS != nil && len(c.Request.TLS.PeerCertificates) > 0 {
+			c.Set(clientContextKey, ClientIdentity(c.Request.TLS.PeerCertificates[0]))
+		}
+	}
+}
+
+// getClientName returns the identity of the client, empty if the client is not authenticated.
+func getClientName(c *gin.Context) string {
+	return c.GetString(clientContextKey)
+}
+
 func LogAndMetricsMiddleware(metricPublisher MetricPublisher) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
+		routeName := getRouteName(c)
 
-		metricPublisher.IncrementTotalRequestCount(c.Request.Method, c.Request.URL.Path)
+		metricPublisher.IncrementTotalRequestCount(routeName, c.Request.Method, c.Request.URL.Path)
 		c.Next()
 
 		latency := time.Since(start)
-		metricPublisher.MeasureTotalDuration(c.Request.Method, c.Request.URL.Path, latency.Seconds())
+		metricPublisher.MeasureTotalDuration(routeName, c.Request.Method, c.Request.URL.Path, c.Writer.Status(), latency.Seconds())
+		client := getClientName(c)
+		if client != "" {
+			metricPublisher.IncrementClientRequestCount(routeName, client)
+		}
 
 		path := c.Request.URL.Path
 		raw := c.Request.URL.RawQuery
@@ -67,15 +92,87 @@ func LogAndMetricsMiddleware(metricPublisher MetricPublisher) gin.HandlerFunc {
 		}
 
 		log.WithFields(log.Fields{
+			"route":       routeName,
 			"method":      c.Request.Method,
 			"path":        path,
 			"latency":     latency.String(),
 			"status_code": c.Writer.Status(),
 			"client_ip":   c.ClientIP(),
+			"client":      client,
 		}).Info("request summary")
 	}
 }
 
+// AuthMiddleware rejects the requests of unauthenticated clients with a 401 response, the requests a client is not
+// allowed to have signed with a 403 response, and the requests whose path cannot be authorized with a 400 response.
+// Clients already identified by their certificate are not asked for other credentials, any credentials they send
+// are still removed from the request.
+func AuthMiddleware(authenticator Authenticator, authorizer Authorizer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer authenticator.StripCredentials(c.Request)
+		client := getClientName(c)
+		if client == "" {
+			var err error
+			client, err = authenticator.Authenticate(c.Request)
+			if err != nil {
+				abortWithAuthError(c, err)
+				return
+			}
+			c.Set(clientContextKey, client)
+		}
+		if err := authorizer.Authorize(client, c.Request); err != nil {
+			abortWithAuthError(c, err)
+		}
+	}
+}
+
+// FilterMiddleware rejects the requests that are not allowed by filter with a 403 response giving the reason, and the
+// requests whose path cannot be matched against its rules with a 400 response.
+func FilterMiddleware(filter *RequestFilter, metricPublisher MetricPublisher) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := filter.Check(c.Request); err != nil {
+			metricPublisher.IncrementDeniedRequestCount(getRouteName(c), c.Request.Method, c.Request.URL.Path)
+			status := http.StatusForbidden
+			if _, ok := err.(*InvalidRequestError); ok {
+				status = http.StatusBadRequest
+			}
+			c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+		}
+	}
+}
+
+// RateLimitMiddleware rejects the requests exceeding a limit of limiter with a 429 response, along with a Retry-After
+// header. Clients are identified by their authenticated name or else by their IP.
+func RateLimitMiddleware(limiter *RateLimiter, metricPublisher MetricPublisher) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client := getClientName(c)
+		if client == "" {
+			client = c.ClientIP()
+		}
+		limit, wait := limiter.Allow(client, c.Request)
+		if wait == 0 {
+			return
+		}
+		metricPublisher.IncrementRateLimitedRequestCount(getRouteName(c), c.Request.Method, c.Request.URL.Path, limit)
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": fmt.Sprintf("%s rate limit exceeded", limit)})
+	}
+}
+
+func abortWithAuthError(c *gin.Context, err error) {
+	errJson := gin.H{"error": err.Error()}
+	switch err.(type) {
+	case *InvalidRequestError:
+		c.AbortWithStatusJSON(http.StatusBadRequest, errJson)
+	case *UnauthorizedError:
+		c.AbortWithStatusJSON(http.StatusUnauthorized, errJson)
+	case *ForbiddenError:
+		c.AbortWithStatusJSON(http.StatusForbidden, errJson)
+	default:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, errJson)
+	}
+}
+
 func CORSMiddleware(accessControlAllowOrigin string) gin.HandlerFunc {
 	if accessControlAllowOrigin != "" {
 		return func(c *gin.Context) {
@@ -0,0 +1,41 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// NormalizePath returns the path of the request that filter, rate limit and authorization rules are matched against,
+// with duplicate slashes removed. Paths that the upstream target may resolve to another path than the one matched are
+// rejected with an InvalidRequestError: paths with dot segments, encoded or not, and paths with encoded slashes.
+func NormalizePath(req *http.Request) (string, error) {
+	// The path is already decoded, so that encoded dots are seen as dots
+	for _, segment := range strings.Split(req.URL.Path, "/") {
+		if segment == "." || segment == ".." {
+			return "", NewInvalidRequestError(errors.New("dot segments are not allowed in the path"))
+		}
+	}
+	if strings.Contains(strings.ToLower(req.URL.EscapedPath()), "%2f") {
+		return "", NewInvalidRequestError(errors.New("encoded slashes are not allowed in the path"))
+	}
+
+	if req.URL.Path == "" {
+		return "/", nil
+	}
+	cleaned := path.Clean(req.URL.Path)
+	if strings.HasSuffix(req.URL.Path, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned, nil
+}
+
+// MatchPathPrefix reports whether the normalized path is under the prefix, which only matches whole segments:
+// /v1/payments matches /v1/payments and /v1/payments/1 but not /v1/payments-admin.
+func MatchPathPrefix(reqPath string, prefix string) bool {
+	if !strings.HasPrefix(reqPath, prefix) {
+		return false
+	}
+	return len(reqPath) == len(prefix) || prefix == "" || strings.HasSuffix(prefix, "/") || reqPath[len(prefix)] == '/'
+}
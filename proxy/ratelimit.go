@@ -0,0 +1,170 @@
+package proxy
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+)
+
+// Names of the limits, reported in the rate limited request metric.
+const (
+	GlobalLimit    = "global"
+	PerClientLimit = "client"
+	PathLimit      = "path"
+)
+
+// clientBucketsSweepInterval is how often the buckets of the clients that have been idle long enough to be
+// refilled are dropped, so that the buckets of past clients do not pile up.
+const clientBucketsSweepInterval = time.Minute
+
+// tokenBucket holds up to burst tokens, refilled at rate tokens per second. It is full until first used.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(cfg config.LimitConfig) *tokenBucket {
+	burst := float64(cfg.Burst)
+	if burst == 0 {
+		burst = math.Ceil(cfg.Rate)
+	}
+	return &tokenBucket{
+		rate:   cfg.Rate,
+		burst:  burst,
+		tokens: burst,
+	}
+}
+
+// wait returns 0 if a token is available, the time until one is available otherwise. The token is not taken.
+func (b *tokenBucket) wait(now time.Time) time.Duration {
+	b.refill(now)
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// take takes a token, which wait must have found available.
+func (b *tokenBucket) take() {
+	b.tokens--
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if b.last.IsZero() {
+		b.last = now
+		return
+	}
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+}
+
+type pathLimit struct {
+	rule   *filterRule
+	bucket *tokenBucket
+}
+
+// RateLimiter limits the rate of the requests globally, per client and per path with token buckets.
+type RateLimiter struct {
+	mu        sync.Mutex
+	now       func() time.Time
+	global    *tokenBucket
+	perClient config.LimitConfig
+	clients   map[string]*tokenBucket
+	lastSweep time.Time
+	paths     []*pathLimit
+}
+
+// NewRateLimiter creates the token buckets of the limits of cfg, limits with a rate of 0 are ignored.
+func NewRateLimiter(cfg config.RateLimitConfig) (*RateLimiter, error) {
+	l := &RateLimiter{
+		now:       time.Now,
+		perClient: cfg.PerClient,
+		clients:   map[string]*tokenBucket{},
+	}
+	if cfg.Global.Rate > 0 {
+		l.global = newTokenBucket(cfg.Global)
+	}
+	for _, pathCfg := range cfg.Paths {
+		if pathCfg.Rate == 0 {
+			continue
+		}
+		rule, err := newFilterRule(pathCfg.Rule())
+		if err != nil {
+			return nil, err
+		}
+		l.paths = append(l.paths, &pathLimit{rule: rule, bucket: newTokenBucket(pathCfg.Limit())})
+	}
+	return l, nil
+}
+
+// Allow takes a token from each bucket the request of client is subject to. If one is empty, no token is taken and
+// Allow returns the name of its limit and the time until a token is available, so that a rejected request does not use
+// up the tokens of the other limits. The client bucket is checked first, then the path buckets and the global one.
+func (l *RateLimiter) Allow(client string, req *http.Request) (string, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+
+	type limitedBucket struct {
+		limit  string
+		bucket *tokenBucket
+	}
+	var buckets []limitedBucket
+	if l.perClient.Rate > 0 {
+		l.sweepClientBuckets(now)
+		bucket, ok := l.clients[client]
+		if !ok {
+			bucket = newTokenBucket(l.perClient)
+			l.clients[client] = bucket
+		}
+		buckets = append(buckets, limitedBucket{PerClientLimit, bucket})
+	}
+	// A path that cannot be normalized is subject to the limits of every path its method matches
+	reqPath, pathErr := NormalizePath(req)
+	for _, path := range l.paths {
+		if pathErr != nil && !path.rule.matchesMethod(req.Method) {
+			continue
+		}
+		if pathErr == nil && !path.rule.matches(req.Method, reqPath) {
+			continue
+		}
+		buckets = append(buckets, limitedBucket{PathLimit, path.bucket})
+	}
+	if l.global != nil {
+		buckets = append(buckets, limitedBucket{GlobalLimit, l.global})
+	}
+
+	for _, b := range buckets {
+		if wait := b.bucket.wait(now); wait > 0 {
+			return b.limit, wait
+		}
+	}
+	for _, b := range buckets {
+		b.bucket.take()
+	}
+	return "", 0
+}
+
+// sweepClientBuckets drops the buckets that are full, they are the same as new ones.
+func (l *RateLimiter) sweepClientBuckets(now time.Time) {
+	if l.lastSweep.IsZero() {
+		l.lastSweep = now
+	}
+	if now.Sub(l.lastSweep) < clientBucketsSweepInterval {
+		return
+	}
+	for client, bucket := range l.clients {
+		bucket.refill(now)
+		if bucket.tokens >= bucket.burst {
+			delete(l.clients, client)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,140 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter, err := NewRateLimiter(config.RateLimitConfig{
+		Global:    config.LimitConfig{Rate: 4, Burst: 4},
+		PerClient: config.LimitConfig{Rate: 1, Burst: 2},
+		Paths: []config.PathLimitConfig{
+			{Methods: []string{"POST"}, Path: "/v1/payments", Rate: 0.5},
+		},
+	})
+	require.NoError(t, err)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	get, err := http.NewRequest(http.MethodGet, "http://localhost/v1/accounts", nil)
+	require.NoError(t, err)
+	post, err := http.NewRequest(http.MethodPost, "http://localhost/v1/payments", nil)
+	require.NoError(t, err)
+	allow := func(client string, req *http.Request) (string, time.Duration) {
+		return limiter.Allow(client, req)
+	}
+
+	// Each client has its own burst
+	for i := 0; i < 2; i++ {
+		limit, wait := allow("billing", get)
+		require.Zero(t, wait)
+		require.Empty(t, limit)
+	}
+	limit, wait := allow("billing", get)
+	require.Equal(t, PerClientLimit, limit)
+	require.Equal(t, time.Second, wait)
+
+	// The path limit has a burst of 1, as its rate rounded up, and is shared by the clients
+	limit, wait = allow("reports", post)
+	require.Zero(t, wait)
+	limit, wait = allow("treasury", post)
+	require.Equal(t, PathLimit, limit)
+	require.Equal(t, 2*time.Second, wait)
+
+	// The global burst is used up, requests rejected by the client and path limits do not take a global token
+	limit, wait = allow("treasury", get)
+	require.Zero(t, wait)
+	limit, wait = allow("payments", get)
+	require.Equal(t, GlobalLimit, limit)
+	require.Equal(t, 250*time.Millisecond, wait)
+
+	// Tokens are refilled over time
+	now = now.Add(time.Second)
+	limit, wait = allow("billing", get)
+	require.Zero(t, wait)
+	require.Empty(t, limit)
+}
+
+func TestRateLimiterRejectionTakesNoToken(t *testing.T) {
+	limiter, err := NewRateLimiter(config.RateLimitConfig{
+		Global:    config.LimitConfig{Rate: 1, Burst: 1},
+		PerClient: config.LimitConfig{Rate: 0.1, Burst: 1},
+		Paths: []config.PathLimitConfig{
+			{Methods: []string{"POST"}, Path: "/v1/payments", Rate: 0.1},
+		},
+	})
+	require.NoError(t, err)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	get, err := http.NewRequest(http.MethodGet, "http://localhost/v1/accounts", nil)
+	require.NoError(t, err)
+	post, err := http.NewRequest(http.MethodPost, "http://localhost/v1/payments", nil)
+	require.NoError(t, err)
+
+	limit, wait := limiter.Allow("billing", get)
+	require.Zero(t, wait)
+	require.Empty(t, limit)
+	limit, wait = limiter.Allow("reports", post)
+	require.Equal(t, GlobalLimit, limit)
+	require.Equal(t, time.Second, wait)
+
+	// The client and path tokens were left for the request once the global bucket is refilled
+	now = now.Add(time.Second)
+	limit, wait = limiter.Allow("reports", post)
+	require.Zero(t, wait)
+	require.Empty(t, limit)
+}
+
+func TestRateLimiterPathNormalization(t *testing.T) {
+	limiter, err := NewRateLimiter(config.RateLimitConfig{
+		Paths: []config.PathLimitConfig{
+			{Methods: []string{"POST"}, Path: "/v1/payments", Rate: 1},
+		},
+	})
+	require.NoError(t, err)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	limit, wait := limiter.Allow("billing", httptest.NewRequest(http.MethodPost, "/v1/payments", nil))
+	require.Zero(t, wait)
+	require.Empty(t, limit)
+
+	// Paths resolving to the limited path share its bucket, paths that cannot be normalized are subject to it too
+	for _, path := range []string{"//v1//payments", "/v1/accounts/%2e%2e/payments", "/v1%2Fpayments"} {
+		limit, wait = limiter.Allow("billing", httptest.NewRequest(http.MethodPost, path, nil))
+		require.Equal(t, PathLimit, limit, path)
+		require.Equal(t, time.Second, wait, path)
+	}
+
+	// Other methods are not
+	limit, wait = limiter.Allow("billing", httptest.NewRequest(http.MethodGet, "/v1/accounts/%2e%2e/payments", nil))
+	require.Zero(t, wait)
+	require.Empty(t, limit)
+}
+
+func TestRateLimiterSweepsClientBuckets(t *testing.T) {
+	limiter, err := NewRateLimiter(config.RateLimitConfig{
+		PerClient: config.LimitConfig{Rate: 1},
+	})
+	require.NoError(t, err)
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	req, err := http.NewRequest(http.MethodGet, "http://localhost/v1/accounts", nil)
+	require.NoError(t, err)
+	limiter.Allow("10.0.0.1", req)
+	limiter.Allow("10.0.0.2", req)
+	require.Len(t, limiter.clients, 2)
+
+	// Buckets of idle clients are dropped
+	now = now.Add(clientBucketsSweepInterval)
+	limiter.Allow("10.0.0.2", req)
+	require.Len(t, limiter.clients, 1)
+}
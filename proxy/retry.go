@@ -0,0 +1,180 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const idempotencyKeyHeader = "Idempotency-Key"
+
+type retryContextKey struct{}
+
+// retryableRequest holds what is needed to sign a request again before retrying it: its body, which is consumed by
+// each attempt.
+type retryableRequest struct {
+	body []byte
+}
+
+// withRetry marks the request as retryable, buffering its body so that it can be sent again. Requests with a body
+// larger than maxBodySize are returned unmarked, to be sent once without buffering more than maxBodySize bytes.
+func withRetry(req *http.Request, maxBodySize int64) (*http.Request, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.ContentLength > maxBodySize {
+			return req, nil
+		}
+		var err error
+		body, err = io.ReadAll(io.LimitReader(req.Body, maxBodySize+1))
+		if err != nil {
+			return nil, NewInvalidRequestError(err)
+		}
+		if int64(len(body)) > maxBodySize {
+			// The length of the body was unknown, the part already read is sent before the rest
+			req.Body = &readCloser{Reader: io.MultiReader(bytes.NewReader(body), req.Body), Closer: req.Body}
+			return req, nil
+		}
+		_ = req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	rr := &retryableRequest{body: body}
+	return req.WithContext(context.WithValue(req.Context(), retryContextKey{}, rr)), nil
+}
+
+// readCloser reads from Reader and closes Closer.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func getRetryableRequest(req *http.Request) *retryableRequest {
+	rr, _ := req.Context().Value(retryContextKey{}).(*retryableRequest)
+	return rr
+}
+
+// isIdempotent reports whether the request can be sent again without side effects, POST requests being idempotent
+// only if the client sets an Idempotency-Key header.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	case http.MethodPost:
+		return req.Header.Get(idempotencyKeyHeader) != ""
+	default:
+		return false
+	}
+}
+
+// retryTransport retries the requests marked as retryable by withRetry. Each retry has a fresh Date header and is
+// signed again, so that the upstream target never receives a stale signature. Retries stop once the circuit breaker,
+// if any, rejects requests.
+type retryTransport struct {
+	next      http.RoundTripper
+	cfg       config.RetryConfig
+	reqSigner RequestSigner
+	breaker   *CircuitBreaker
+}
+
+func newRetryTransport(next http.RoundTripper, cfg config.RetryConfig, reqSigner RequestSigner, breaker *CircuitBreaker) *retryTransport {
+	return &retryTransport{
+		next:      next,
+		cfg:       cfg,
+		reqSigner: reqSigner,
+		breaker:   breaker,
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rr := getRetryableRequest(req)
+	if rr == nil {
+		return t.next.RoundTrip(req)
+	}
+
+	attemptReq := req
+	for retry := 1; ; retry++ {
+		resp, err := t.next.RoundTrip(attemptReq)
+		if retry > t.cfg.MaxRetries || !shouldRetry(resp, err) || req.Context().Err() != nil {
+			return resp, err
+		}
+		// No retry is sent once the breaker rejects requests, the response of the last attempt is returned
+		if t.breaker != nil {
+			if ok, _ := t.breaker.Allow(); !ok {
+				return resp, err
+			}
+		}
+
+		fields := log.Fields{"method": req.Method, "path": req.URL.Path, "retry": retry}
+		if err != nil {
+			fields["error"] = err.Error()
+		} else {
+			fields["status"] = resp.StatusCode
+			// Drain the body so that the connection can be reused
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+		log.WithFields(fields).Warn("retrying upstream request")
+
+		select {
+		case <-time.After(t.backoff(retry)):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+
+		attemptReq, err = t.resign(req, rr)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// resign returns a copy of the request sent by the reverse proxy with a fresh Date header and signature.
+func (t *retryTransport) resign(req *http.Request, rr *retryableRequest) (*http.Request, error) {
+	retryReq := req.Clone(req.Context())
+	retryReq.Body = http.NoBody
+	if len(rr.body) > 0 {
+		retryReq.Body = io.NopCloser(bytes.NewReader(rr.body))
+	}
+	retryReq.Header.Set("Date", time.Now().Format(http.TimeFormat))
+	// The request already points to the upstream target, as when it was first signed, the signature headers are
+	// replaced
+	return t.reqSigner.SignRequest(retryReq)
+}
+
+// backoff returns the delay before the given retry, doubling from the initial backoff up to the max backoff and
+// randomly reduced by up to half to spread the retries of concurrent requests.
+func (t *retryTransport) backoff(retry int) time.Duration {
+	delay := t.cfg.InitialBackoff
+	for i := 1; i < retry && delay < t.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > t.cfg.MaxBackoff {
+		delay = t.cfg.MaxBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// shouldRetry reports whether the upstream target may succeed if the request is sent again.
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+			errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+	}
+	return isUpstreamFailure(resp, nil)
+}
+
+func (t *retryTransport) CloseIdleConnections() {
+	if closer, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
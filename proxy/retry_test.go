@@ -0,0 +1,241 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	"github.com/form3tech-oss/http-message-signing-proxy/test"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+// countingSigner signs requests with the number of signatures made so far, and records the URL they are signed with.
+type countingSigner struct {
+	mu    sync.Mutex
+	count int
+}
+
+func (s *countingSigner) SignRequest(req *http.Request) (*http.Request, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.count++
+	req.Header.Set("Signature", strconv.Itoa(s.count))
+	req.Header.Set("X-Signed-Url", req.URL.String())
+	return req, nil
+}
+
+type upstreamAttempt struct {
+	url       string
+	signedURL string
+	signature string
+	date      string
+	body      string
+}
+
+func TestRetry(t *testing.T) {
+	clientDate := time.Date(1998, time.May, 1, 1, 2, 3, 0, time.UTC).Format(http.TimeFormat)
+	tests := []struct {
+		name             string
+		method           string
+		header           http.Header
+		body             string
+		failures         int
+		closeConn        bool
+		unknownLength    bool
+		circuitBreaker   config.CircuitBreakerConfig
+		expectedStatus   int
+		expectedAttempts int
+	}{
+		{
+			name:             "idempotent request is retried until it succeeds",
+			method:           http.MethodPut,
+			body:             `{"amount":"10.00"}`,
+			failures:         2,
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 3,
+		},
+		{
+			name:             "request is retried when the connection is closed",
+			method:           http.MethodGet,
+			failures:         1,
+			closeConn:        true,
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 2,
+		},
+		{
+			name:             "retries are limited",
+			method:           http.MethodGet,
+			failures:         5,
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedAttempts: 4,
+		},
+		{
+			name:             "POST request is not retried",
+			method:           http.MethodPost,
+			body:             `{"amount":"10.00"}`,
+			failures:         1,
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "POST request with an idempotency key is retried",
+			method:           http.MethodPost,
+			header:           http.Header{"Idempotency-Key": []string{"4f0b4e3c"}},
+			body:             `{"amount":"10.00"}`,
+			failures:         1,
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 2,
+		},
+		{
+			name:             "request with a body over the max body size is not retried",
+			method:           http.MethodPut,
+			body:             `{"amount":"10.00","currency":"GBP"}`,
+			failures:         1,
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "request with a body of unknown length over the max body size is not retried",
+			method:           http.MethodPut,
+			body:             `{"amount":"10.00","currency":"GBP"}`,
+			unknownLength:    true,
+			failures:         1,
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "request with a body of unknown length is retried",
+			method:           http.MethodPut,
+			body:             `{"amount":"10.00"}`,
+			unknownLength:    true,
+			failures:         1,
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 2,
+		},
+		{
+			name:             "retries stop once the circuit breaker opens",
+			method:           http.MethodGet,
+			failures:         5,
+			circuitBreaker:   config.CircuitBreakerConfig{Enable: true, ConsecutiveFailures: 2, Cooldown: time.Minute},
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedAttempts: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockMetricPublisher := mockMetricPublisher(mockCtrl, "test", tt.method, "/payments/1")
+			mockMetricPublisher.EXPECT().SetCircuitBreakerState("test", gomock.Any()).AnyTimes()
+
+			// Test upstream target failing the first requests
+			var attempts []upstreamAttempt
+			targetSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				attempts = append(attempts, upstreamAttempt{
+					url:       "http://" + r.Host + r.URL.String(),
+					signedURL: r.Header.Get("X-Signed-Url"),
+					signature: r.Header.Get("Signature"),
+					date:      r.Header.Get("Date"),
+					body:      string(body),
+				})
+				if len(attempts) <= tt.failures && tt.closeConn {
+					conn, _, err := w.(http.Hijacker).Hijack()
+					require.NoError(t, err)
+					_ = conn.Close()
+					return
+				}
+				if len(attempts) <= tt.failures {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer targetSrv.Close()
+
+			// Upstream target with a base path, joined to the request path before signing
+			route, err := NewRoute(config.RouteConfig{
+				Name:           "test",
+				UpstreamTarget: targetSrv.URL + "/base",
+				Upstream: config.UpstreamConfig{
+					Retry: config.RetryConfig{
+						MaxRetries:     3,
+						InitialBackoff: time.Millisecond,
+						MaxBackoff:     5 * time.Millisecond,
+						MaxBodySize:    int64(len(`{"amount":"10.00"}`)),
+					},
+					CircuitBreaker: tt.circuitBreaker,
+				},
+			}, &countingSigner{}, mockMetricPublisher)
+			require.NoError(t, err)
+
+			h := NewHandler([]*Route{route}, mockMetricPublisher)
+			_, e := gin.CreateTestContext(nil)
+			e.NoRoute(h.SelectRoute, LogAndMetricsMiddleware(mockMetricPublisher), h.ForwardRequest)
+
+			req, err := http.NewRequest(tt.method, "/payments/1", strings.NewReader(tt.body))
+			require.NoError(t, err)
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+			if tt.unknownLength {
+				req.ContentLength = -1
+			}
+			req.Header.Set("Date", clientDate)
+			w := test.NewTestResponseRecorder()
+			e.ServeHTTP(w, req)
+
+			require.Equal(t, tt.expectedStatus, w.Code)
+			require.Len(t, attempts, tt.expectedAttempts)
+			for i, attempt := range attempts {
+				// Each attempt is signed again with the URL and body it is sent with, retries with a fresh Date header
+				require.Equal(t, strconv.Itoa(i+1), attempt.signature)
+				require.Equal(t, targetSrv.URL+"/base/payments/1", attempt.url)
+				require.Equal(t, attempt.url, attempt.signedURL)
+				require.Equal(t, tt.body, attempt.body)
+				if i == 0 {
+					require.Equal(t, clientDate, attempt.date)
+				} else {
+					require.NotEqual(t, clientDate, attempt.date)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	transport := newRetryTransport(nil, config.RetryConfig{
+		MaxRetries:     10,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+	}, nil, nil)
+
+	tests := []struct {
+		retry       int
+		expectedMax time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{10, time.Second},
+	}
+	for _, tt := range tests {
+		// The jitter reduces the backoff by up to half
+		for i := 0; i < 10; i++ {
+			backoff := transport.backoff(tt.retry)
+			require.GreaterOrEqual(t, backoff, tt.expectedMax/2)
+			require.LessOrEqual(t, backoff, tt.expectedMax)
+		}
+	}
+}
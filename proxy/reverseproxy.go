@@ -2,23 +2,162 @@ package proxy
 
 import (
 	"fmt"
+	"net"
+	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
 )
 
 type ReverseProxy struct {
 	*httputil.ReverseProxy
-	TargetHost string
+	// Target is the upstream target, requests are pointed to it by Rewrite before being signed
+	Target *url.URL
+	// Breaker rejects the requests while the upstream target is failing, nil if the circuit breaker is disabled
+	Breaker *CircuitBreaker
 }
 
-func NewReverseProxy(target string) (*ReverseProxy, error) {
+func NewReverseProxy(target string, upstreamCfg config.UpstreamConfig) (*ReverseProxy, error) {
 	upstreamURL, err := url.Parse(target)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse upstream target: %w", err)
 	}
-	rp := httputil.NewSingleHostReverseProxy(upstreamURL)
+	transport, err := NewTransport(upstreamCfg)
+	if err != nil {
+		return nil, err
+	}
+	rp := &httputil.ReverseProxy{
+		// The request is already pointed to the upstream target by Rewrite, so that the URL it is signed with is
+		// the URL the upstream target receives
+		Director: func(req *http.Request) {
+			if _, ok := req.Header["User-Agent"]; !ok {
+				// Explicitly disable the default User-Agent of the http client
+				req.Header.Set("User-Agent", "")
+			}
+		},
+		Transport: transport,
+	}
 	return &ReverseProxy{
 		ReverseProxy: rp,
-		TargetHost:   upstreamURL.Host,
+		Target:       upstreamURL,
 	}, nil
 }
+
+// Rewrite points the request to the upstream target, joining the path and query of the upstream target with the
+// ones of the request, the same way as httputil.NewSingleHostReverseProxy.
+func (rp *ReverseProxy) Rewrite(req *http.Request) {
+	target := rp.Target
+	req.URL.Scheme = target.Scheme
+	req.URL.Host = target.Host
+	req.URL.Path, req.URL.RawPath = joinURLPath(target, req.URL)
+	if target.RawQuery == "" || req.URL.RawQuery == "" {
+		req.URL.RawQuery = target.RawQuery + req.URL.RawQuery
+	} else {
+		req.URL.RawQuery = target.RawQuery + "&" + req.URL.RawQuery
+	}
+	req.Host = target.Host
+	req.Header.Set("Host", target.Host)
+}
+
+func joinURLPath(a, b *url.URL) (path, rawpath string) {
+	if a.RawPath == "" && b.RawPath == "" {
+		return singleJoiningSlash(a.Path, b.Path), ""
+	}
+	// Same as singleJoiningSlash, but uses EscapedPath to determine whether a slash should be added
+	apath := a.EscapedPath()
+	bpath := b.EscapedPath()
+
+	aslash := strings.HasSuffix(apath, "/")
+	bslash := strings.HasPrefix(bpath, "/")
+
+	switch {
+	case aslash && bslash:
+		return a.Path + b.Path[1:], apath + bpath[1:]
+	case !aslash && !bslash:
+		return a.Path + "/" + b.Path, apath + "/" + bpath
+	}
+	return a.Path + b.Path, apath + bpath
+}
+
+func singleJoiningSlash(a, b string) string {
+	aslash := strings.HasSuffix(a, "/")
+	bslash := strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
+}
+
+// NewTransport returns a transport to the upstream target with the limits and TLS config of cfg.
+// Like http.DefaultTransport, it honours the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+func NewTransport(upstreamCfg config.UpstreamConfig) (*http.Transport, error) {
+	tlsCfg, err := NewUpstreamTLSConfig(upstreamCfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := upstreamCfg.Transport
+	dialer := &net.Dialer{
+		Timeout:   cfg.DialTimeout,
+		KeepAlive: cfg.KeepAlive,
+	}
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsCfg,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		ExpectContinueTimeout: cfg.ExpectContinueTimeout,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		DisableKeepAlives:     cfg.DisableKeepAlives,
+	}, nil
+}
+
+// metricsTransport measures how long the upstream target takes to respond and counts its responses by status class.
+type metricsTransport struct {
+	next            http.RoundTripper
+	route           string
+	metricPublisher MetricPublisher
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		// Requests that got no response are counted as upstream errors
+		return resp, err
+	}
+	class := statusClass(resp.StatusCode)
+	t.metricPublisher.MeasureUpstreamDuration(t.route, req.Method, class, time.Since(start).Seconds())
+	t.metricPublisher.IncrementUpstreamResponseCount(t.route, req.Method, class)
+	return resp, nil
+}
+
+func (t *metricsTransport) CloseIdleConnections() {
+	if closer, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// statusClass returns the class of the status code, e.g. 5xx.
+func statusClass(statusCode int) string {
+	return strconv.Itoa(statusCode/100) + "xx"
+}
+
+// CloseIdleConnections closes the idle connections to the upstream target, e.g. once the route is replaced.
+func (rp *ReverseProxy) CloseIdleConnections() {
+	if closer, ok := rp.Transport.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTransport(t *testing.T) {
+	transport, err := NewTransport(config.UpstreamConfig{
+		Transport: config.TransportConfig{
+			TLSHandshakeTimeout:   time.Second,
+			ResponseHeaderTimeout: 2 * time.Second,
+			ExpectContinueTimeout: 3 * time.Second,
+			IdleConnTimeout:       4 * time.Second,
+			MaxIdleConns:          5,
+			MaxIdleConnsPerHost:   6,
+			MaxConnsPerHost:       7,
+			DisableKeepAlives:     true,
+		},
+		TLS: config.UpstreamTLSConfig{
+			ServerName: "api.example.com",
+			MinVersion: "1.3",
+		},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, time.Second, transport.TLSHandshakeTimeout)
+	require.Equal(t, 2*time.Second, transport.ResponseHeaderTimeout)
+	require.Equal(t, 3*time.Second, transport.ExpectContinueTimeout)
+	require.Equal(t, 4*time.Second, transport.IdleConnTimeout)
+	require.Equal(t, 5, transport.MaxIdleConns)
+	require.Equal(t, 6, transport.MaxIdleConnsPerHost)
+	require.Equal(t, 7, transport.MaxConnsPerHost)
+	require.True(t, transport.DisableKeepAlives)
+	require.NotNil(t, transport.Proxy)
+	require.Equal(t, "api.example.com", transport.TLSClientConfig.ServerName)
+	require.Equal(t, uint16(tls.VersionTLS13), transport.TLSClientConfig.MinVersion)
+}
+
+func TestReverseProxyResponseHeaderTimeout(t *testing.T) {
+	// Test upstream target slower than the response header timeout
+	targetSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer targetSrv.Close()
+
+	rp, err := NewReverseProxy(targetSrv.URL, config.UpstreamConfig{
+		Transport: config.TransportConfig{ResponseHeaderTimeout: 50 * time.Millisecond},
+	})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rp.Rewrite(req)
+	w := httptest.NewRecorder()
+	start := time.Now()
+	rp.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusBadGateway, w.Code)
+	require.Less(t, time.Since(start), time.Second)
+}
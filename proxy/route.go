@@ -0,0 +1,143 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	routeContextKey = "signing_proxy_route"
+)
+
+// Route forwards the requests it matches to its own upstream target, signed with its own signer.
+type Route struct {
+	Name      string
+	Proxy     *ReverseProxy
+	ReqSigner RequestSigner
+	match     config.MatchConfig
+	retry     config.RetryConfig
+
+	// inFlight counts the requests using the route, drained is closed once the route is retired and they are done
+	mu       sync.Mutex
+	inFlight int
+	retired  bool
+	drained  chan struct{}
+}
+
+func NewRoute(cfg config.RouteConfig, reqSigner RequestSigner, metricPublisher MetricPublisher) (*Route, error) {
+	rp, err := NewReverseProxy(cfg.UpstreamTarget, cfg.Upstream)
+	if err != nil {
+		return nil, err
+	}
+	rp.ErrorHandler = NewUpstreamErrorHandler(cfg.Name, metricPublisher)
+	rp.Transport = &metricsTransport{next: rp.Transport, route: cfg.Name, metricPublisher: metricPublisher}
+	// The breaker records the outcome of each attempt, retries included
+	if cfg.Upstream.CircuitBreaker.Enable {
+		rp.Breaker = NewCircuitBreaker(cfg.Name, cfg.Upstream.CircuitBreaker, metricPublisher)
+		rp.Transport = &breakerTransport{next: rp.Transport, breaker: rp.Breaker}
+	}
+	if cfg.Upstream.Retry.MaxRetries > 0 {
+		rp.Transport = newRetryTransport(rp.Transport, cfg.Upstream.Retry, reqSigner, rp.Breaker)
+	}
+	return &Route{
+		Name:      cfg.Name,
+		Proxy:     rp,
+		ReqSigner: reqSigner,
+		match:     cfg.Match,
+		retry:     cfg.Upstream.Retry,
+		drained:   make(chan struct{}),
+	}, nil
+}
+
+// Matches reports whether the request satisfies all match conditions of the route.
+// A route without any condition matches every request. The path prefix matches whole segments of the normalized
+// path, a path that cannot be normalized does not match it.
+func (r *Route) Matches(req *http.Request) bool {
+	if r.match.PathPrefix != "" {
+		reqPath, err := NormalizePath(req)
+		if err != nil || !MatchPathPrefix(reqPath, r.match.PathPrefix) {
+			return false
+		}
+	}
+	if r.match.Host != "" && !matchHost(req.Host, r.match.Host) {
+		return false
+	}
+	if r.match.Header.Name != "" {
+		value := req.Header.Get(r.match.Header.Name)
+		if value == "" || (r.match.Header.Value != "" && value != r.match.Header.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchHost compares hosts case-insensitively, the port of the request host is ignored
+// unless the expected host has one.
+func matchHost(reqHost string, host string) bool {
+	if strings.EqualFold(reqHost, host) {
+		return true
+	}
+	hostname, _, err := net.SplitHostPort(reqHost)
+	return err == nil && strings.EqualFold(hostname, host)
+}
+
+// acquire marks a request as using the route until it calls release. It fails once the route is retired.
+func (r *Route) acquire() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.retired {
+		return false
+	}
+	r.inFlight++
+	return true
+}
+
+func (r *Route) release() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.inFlight--
+	if r.retired && r.inFlight == 0 {
+		close(r.drained)
+	}
+}
+
+// Retire stops the route from being used by new requests, e.g. once it is replaced by a reload. The returned channel
+// is closed once the requests in progress on the route are done, its signer can then be closed.
+func (r *Route) Retire() <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.retired {
+		r.retired = true
+		if r.inFlight == 0 {
+			close(r.drained)
+		}
+	}
+	return r.drained
+}
+
+// canRetry reports whether the request is retried by the route if the upstream target fails.
+func (r *Route) canRetry(req *http.Request) bool {
+	return r.retry.MaxRetries > 0 && isIdempotent(req)
+}
+
+// getRoute returns the route selected for the request, nil if none matched.
+func getRoute(c *gin.Context) *Route {
+	route, ok := c.Get(routeContextKey)
+	if !ok {
+		return nil
+	}
+	return route.(*Route)
+}
+
+// getRouteName returns the name of the route selected for the request, empty if none matched.
+func getRouteName(c *gin.Context) string {
+	if route := getRoute(c); route != nil {
+		return route.Name
+	}
+	return ""
+}
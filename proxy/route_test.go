@@ -0,0 +1,141 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		match    config.MatchConfig
+		url      string
+		headers  map[string]string
+		expected bool
+	}{
+		{
+			"no condition",
+			config.MatchConfig{},
+			"http://localhost:8080/v1/payments",
+			nil,
+			true,
+		},
+		{
+			"path prefix",
+			config.MatchConfig{PathPrefix: "/v1/"},
+			"http://localhost:8080/v1/payments",
+			nil,
+			true,
+		},
+		{
+			"path prefix mismatch",
+			config.MatchConfig{PathPrefix: "/v2/"},
+			"http://localhost:8080/v1/payments",
+			nil,
+			false,
+		},
+		{
+			"path prefix matching whole segments",
+			config.MatchConfig{PathPrefix: "/payments"},
+			"http://localhost:8080/payments-admin",
+			nil,
+			false,
+		},
+		{
+			"path prefix with dot segments",
+			config.MatchConfig{PathPrefix: "/payments"},
+			"http://localhost:8080/payments/../admin",
+			nil,
+			false,
+		},
+		{
+			"path prefix with duplicate slashes",
+			config.MatchConfig{PathPrefix: "/payments"},
+			"http://localhost:8080//payments",
+			nil,
+			true,
+		},
+		{
+			"path prefix equal to the path",
+			config.MatchConfig{PathPrefix: "/payments"},
+			"http://localhost:8080/payments",
+			nil,
+			true,
+		},
+		{
+			"host ignoring port",
+			config.MatchConfig{Host: "LOCALHOST"},
+			"http://localhost:8080/v1/payments",
+			nil,
+			true,
+		},
+		{
+			"host with port",
+			config.MatchConfig{Host: "localhost:8080"},
+			"http://localhost:8080/v1/payments",
+			nil,
+			true,
+		},
+		{
+			"host mismatch",
+			config.MatchConfig{Host: "localhost:9090"},
+			"http://localhost:8080/v1/payments",
+			nil,
+			false,
+		},
+		{
+			"header present",
+			config.MatchConfig{Header: config.HeaderMatchConfig{Name: "X-Counterparty"}},
+			"http://localhost:8080/v1/payments",
+			map[string]string{"X-Counterparty": "bank"},
+			true,
+		},
+		{
+			"header missing",
+			config.MatchConfig{Header: config.HeaderMatchConfig{Name: "X-Counterparty"}},
+			"http://localhost:8080/v1/payments",
+			nil,
+			false,
+		},
+		{
+			"header value mismatch",
+			config.MatchConfig{Header: config.HeaderMatchConfig{Name: "X-Counterparty", Value: "bank"}},
+			"http://localhost:8080/v1/payments",
+			map[string]string{"X-Counterparty": "other"},
+			false,
+		},
+		{
+			"all conditions",
+			config.MatchConfig{
+				PathPrefix: "/v1/",
+				Host:       "localhost",
+				Header:     config.HeaderMatchConfig{Name: "X-Counterparty", Value: "bank"},
+			},
+			"http://localhost:8080/v1/payments",
+			map[string]string{"X-Counterparty": "bank"},
+			true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			route, err := NewRoute(config.RouteConfig{
+				Name:           "test",
+				Match:          test.match,
+				UpstreamTarget: "http://upstream",
+			}, nil, nil)
+			require.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodGet, test.url, nil)
+			require.NoError(t, err)
+			for k, v := range test.headers {
+				req.Header.Set(k, v)
+			}
+
+			require.Equal(t, test.expected, route.Matches(req))
+		})
+	}
+}
@@ -2,85 +2,220 @@ package proxy
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
+	"sync/atomic"
 	"time"
 
 	"github.com/form3tech-oss/http-message-signing-proxy/config"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 )
 
 type Server struct {
 	http.Server
-	sslConfig config.SSLConfig
+	metric              MetricPublisher
+	sslConfig           config.SSLConfig
+	shutdownGracePeriod time.Duration
+	registry            *prometheus.Registry
+	// ready is closed once the server listens on listenAddr
+	ready      chan struct{}
+	listenAddr net.Addr
+	// cors holds the gin.HandlerFunc setting the CORS headers, replaced when the config is reloaded
+	cors atomic.Value
+	// auth holds the gin.HandlerFunc authenticating and authorizing the clients, replaced when the config is reloaded
+	auth atomic.Value
+	// filter holds the gin.HandlerFunc rejecting the requests denied by the filter rules, replaced when the config
+	// is reloaded
+	filter atomic.Value
+	// rateLimit holds the gin.HandlerFunc rejecting the requests exceeding the rate limits, replaced when the config
+	// is reloaded
+	rateLimit atomic.Value
 }
 
-func NewServer(cfg config.ServerConfig, handler Handler, metric MetricPublisher) *Server {
+// NewServer creates a server exposing the metrics of registry, which should be the registry metric is registered with.
+// A new registry is created if registry is nil.
+func NewServer(cfg config.ServerConfig, handler Handler, metric MetricPublisher, registry *prometheus.Registry) *Server {
+	if registry == nil {
+		registry = prometheus.NewRegistry()
+	}
+	s := &Server{
+		metric:              metric,
+		sslConfig:           cfg.SSL,
+		shutdownGracePeriod: cfg.ShutdownGracePeriod,
+		registry:            registry,
+		ready:               make(chan struct{}),
+	}
+	metricsHandler := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+
+	s.SetAccessControlAllowOrigin(cfg.AccessControlAllowOrigin)
+	s.SetAuth(nil, nil)
+	s.SetRequestFilter(nil)
+	s.SetRateLimiter(nil)
+
 	router := gin.New()
+	// The client IP is only read from the X-Forwarded-For and X-Real-IP headers of the trusted proxies, so that
+	// clients cannot choose the IP their per-client rate limit applies to
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.WithError(err).Error("invalid trusted proxies, no proxy is trusted")
+		_ = router.SetTrustedProxies(nil)
+	}
 
 	router.GET("/-/health", handler.Health)
 	router.GET("/-/prometheus", func(c *gin.Context) {
-		promhttp.Handler().ServeHTTP(c.Writer, c.Request)
+		metricsHandler.ServeHTTP(c.Writer, c.Request)
 	})
 
 	// NoRoute means all other routes.
 	// We cannot use wildcard here because it will conflict with /-/health and /-/prometheus above.
 	router.NoRoute(
 		RecoverMiddleware(metric),
+		ClientCertMiddleware(),
+		handler.SelectRoute,
 		LogAndMetricsMiddleware(metric),
-		CORSMiddleware(cfg.AccessControlAllowOrigin),
+		func(c *gin.Context) {
+			s.cors.Load().(gin.HandlerFunc)(c)
+		},
+		func(c *gin.Context) {
+			s.auth.Load().(gin.HandlerFunc)(c)
+		},
+		func(c *gin.Context) {
+			s.filter.Load().(gin.HandlerFunc)(c)
+		},
+		func(c *gin.Context) {
+			s.rateLimit.Load().(gin.HandlerFunc)(c)
+		},
 		handler.ForwardRequest,
 	)
 
-	return &Server{
-		Server: http.Server{
-			Addr:    fmt.Sprintf(":%d", cfg.Port),
-			Handler: router,
-		},
-		sslConfig: cfg.SSL,
+	s.Server = http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.Port),
+		Handler: router,
+	}
+	return s
+}
+
+// WrapListener returns a listener serving TLS with the server certificate if SSL is enabled, l otherwise.
+// The certificates are loaded here rather than when serving, so that errors are reported before the server starts.
+func (s *Server) WrapListener(l net.Listener) (net.Listener, error) {
+	if !s.sslConfig.Enable {
+		return l, nil
+	}
+	tlsCfg, err := NewServerTLSConfig(s.sslConfig)
+	if err != nil {
+		return nil, err
+	}
+	return tls.NewListener(l, tlsCfg), nil
+}
+
+// Registry returns the registry of the metrics exposed by the server.
+func (s *Server) Registry() *prometheus.Registry {
+	return s.registry
+}
+
+// SetAccessControlAllowOrigin replaces the value of the Access-Control-Allow-Origin header set on the following responses.
+func (s *Server) SetAccessControlAllowOrigin(accessControlAllowOrigin string) {
+	s.cors.Store(CORSMiddleware(accessControlAllowOrigin))
+}
+
+// SetAuth replaces the authentication and authorization of the clients for the following requests.
+// Every client is allowed if authenticator is nil.
+func (s *Server) SetAuth(authenticator Authenticator, authorizer Authorizer) {
+	if authenticator == nil {
+		s.auth.Store(gin.HandlerFunc(func(_ *gin.Context) {}))
+		return
+	}
+	s.auth.Store(AuthMiddleware(authenticator, authorizer))
+}
+
+// SetRequestFilter replaces the filter of the following requests. Every request is allowed if filter is nil.
+func (s *Server) SetRequestFilter(filter *RequestFilter) {
+	if filter == nil {
+		s.filter.Store(gin.HandlerFunc(func(_ *gin.Context) {}))
+		return
+	}
+	s.filter.Store(FilterMiddleware(filter, s.metric))
+}
+
+// SetRateLimiter replaces the rate limiter of the following requests. Requests are not limited if limiter is nil.
+func (s *Server) SetRateLimiter(limiter *RateLimiter) {
+	if limiter == nil {
+		s.rateLimit.Store(gin.HandlerFunc(func(_ *gin.Context) {}))
+		return
 	}
+	s.rateLimit.Store(RateLimitMiddleware(limiter, s.metric))
 }
 
-func (s *Server) Start() {
-	// Initializing the server in a goroutine so that
-	// it won't block the graceful shutdown handling below
+// Start listens on the server port and serves requests until ctx is done. The server is then shut down gracefully,
+// requests in progress are given the shutdown grace period to finish. Start returns nil once the server is stopped,
+// either by ctx or by Shutdown, or an error if it cannot listen or serve. Start must only be called once.
+func (s *Server) Start(ctx context.Context) error {
+	l, err := (&net.ListenConfig{}).Listen(ctx, "tcp", s.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.Addr, err)
+	}
+	return s.StartWithListener(ctx, l)
+}
+
+// StartWithListener is the same as Start, serving on l rather than on the server port.
+func (s *Server) StartWithListener(ctx context.Context, l net.Listener) error {
+	serverListener, err := s.WrapListener(l)
+	if err != nil {
+		_ = l.Close()
+		return err
+	}
+	s.listenAddr = serverListener.Addr()
+	close(s.ready)
+
+	served := make(chan error, 1)
 	go func() {
-		var err error
 		if s.sslConfig.Enable {
-			log.Info("starting server in TLS mode")
-			err = s.ListenAndServeTLS(s.sslConfig.CertFilePath, s.sslConfig.KeyFilePath)
+			log.WithFields(log.Fields{
+				"addr":        s.listenAddr.String(),
+				"client_auth": s.sslConfig.ClientAuth.Enable,
+			}).Info("starting server in TLS mode")
 		} else {
-			log.Info("starting server without TLS")
-			err = s.ListenAndServe()
-		}
-		if err != nil {
-			log.Fatalf("failed to start server: %s", err)
+			log.WithField("addr", s.listenAddr.String()).Info("starting server without TLS")
 		}
+		served <- s.Serve(serverListener)
 	}()
 
-	// Wait for interrupt signal to gracefully shutdown the server with
-	// a timeout of 5 seconds.
-	quit := make(chan os.Signal, 1)
-	// kill (no param) default send syscall.SIGTERM
-	// kill -2 is syscall.SIGINT
-	// kill -9 is syscall.SIGKILL but can't be caught, so don't need to add it
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-	log.Info("shutting down server")
+	select {
+	case err := <-served:
+		// ErrServerClosed means the server was stopped by Shutdown
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return fmt.Errorf("failed to serve: %w", err)
+	case <-ctx.Done():
+	}
 
-	// The context is used to inform the server it has 5 seconds to finish
-	// the request it is currently handling
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	log.Info("shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownGracePeriod)
 	defer cancel()
-
-	if err := s.Shutdown(ctx); err != nil {
-		log.Fatalf("failed to shutdown server: %s", err)
+	if err := s.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shutdown server: %w", err)
 	}
-
 	log.Info("server stopped")
+	return nil
+}
+
+// Ready is closed once the server listens, its address is then returned by ListenAddr.
+func (s *Server) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// ListenAddr returns the address the server listens on, nil until Ready is closed.
+func (s *Server) ListenAddr() net.Addr {
+	select {
+	case <-s.ready:
+		return s.listenAddr
+	default:
+		return nil
+	}
 }
@@ -0,0 +1,130 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	"github.com/form3tech-oss/http-message-signing-proxy/test"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T, cfg config.ServerConfig) *Server {
+	mockCtrl := gomock.NewController(t)
+	return NewServer(cfg, NewHandler(nil, NewMockMetricPublisher(mockCtrl)), NewMockMetricPublisher(mockCtrl), nil)
+}
+
+func TestServerStart(t *testing.T) {
+	s := newTestServer(t, config.ServerConfig{ShutdownGracePeriod: time.Second})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	require.Nil(t, s.ListenAddr())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- s.StartWithListener(ctx, l)
+	}()
+
+	select {
+	case <-s.Ready():
+	case <-time.After(5 * time.Second):
+		t.Fatal("server not ready")
+	}
+	resp, err := http.Get("http://" + s.ListenAddr().String() + "/-/health")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// The server is stopped when the context is done
+	cancel()
+	select {
+	case err := <-stopped:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server not stopped")
+	}
+	_, err = http.Get("http://" + s.ListenAddr().String() + "/-/health")
+	require.Error(t, err)
+}
+
+func TestServerStartErrors(t *testing.T) {
+	// A port already in use
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	tests := []struct {
+		name string
+		cfg  config.ServerConfig
+	}{
+		{
+			"port in use",
+			config.ServerConfig{Port: l.Addr().(*net.TCPAddr).Port},
+		},
+		{
+			"missing certificate",
+			config.ServerConfig{
+				Port: 0,
+				SSL: config.SSLConfig{
+					Enable:       true,
+					CertFilePath: "missing.crt",
+					KeyFilePath:  "missing.key",
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestServer(t, test.cfg)
+			require.Error(t, s.Start(context.Background()))
+			require.Nil(t, s.ListenAddr())
+		})
+	}
+}
+
+func TestServerTrustedProxies(t *testing.T) {
+	targetSrv := testTargetServer("OK")
+	defer targetSrv.Close()
+
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		expectedCodes  []int
+	}{
+		// Spoofed X-Forwarded-For headers do not give the client another per-client bucket
+		{"no trusted proxy", nil, []int{http.StatusOK, http.StatusTooManyRequests}},
+		{"untrusted proxy", []string{"10.0.0.0/8"}, []int{http.StatusOK, http.StatusTooManyRequests}},
+		// Each client behind a trusted proxy has its own bucket
+		{"trusted proxy", []string{"192.0.2.0/24"}, []int{http.StatusOK, http.StatusOK}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			mockMetricPublisher := mockMetricPublisher(mockCtrl, "test", http.MethodGet, "/payments/1")
+			mockMetricPublisher.EXPECT().IncrementRateLimitedRequestCount("test", http.MethodGet, "/payments/1", PerClientLimit).AnyTimes()
+			route := testRoute(t, config.MatchConfig{}, targetSrv.URL, mockReqSigner(mockCtrl), mockMetricPublisher)
+
+			s := NewServer(config.ServerConfig{TrustedProxies: tt.trustedProxies}, NewHandler([]*Route{route}, mockMetricPublisher), mockMetricPublisher, nil)
+			limiter, err := NewRateLimiter(config.RateLimitConfig{PerClient: config.LimitConfig{Rate: 0.1}})
+			require.NoError(t, err)
+			s.SetRateLimiter(limiter)
+
+			for i, forwardedFor := range []string{"203.0.113.1", "203.0.113.2"} {
+				// The requests come from 192.0.2.1
+				req := httptest.NewRequest(http.MethodGet, "/payments/1", nil)
+				req.Header.Set("X-Forwarded-For", forwardedFor)
+				w := test.NewTestResponseRecorder()
+				s.Handler.ServeHTTP(w, req)
+				require.Equal(t, tt.expectedCodes[i], w.Code, forwardedFor)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	log "github.com/sirupsen/logrus"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// NewUpstreamTLSConfig returns the TLS config of the connections to the upstream target.
+func NewUpstreamTLSConfig(cfg config.UpstreamTLSConfig) (*tls.Config, error) {
+	tlsCfg := &tls.Config{
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify, //nolint:gosec // only allowed in dev mode by the config validation
+	}
+	if cfg.InsecureSkipVerify {
+		log.Warn("upstream certificate verification is disabled, this must only be used in development")
+	}
+
+	if cfg.MinVersion != "" {
+		version, ok := tlsVersions[cfg.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS version '%s'", cfg.MinVersion)
+		}
+		tlsCfg.MinVersion = version
+	}
+
+	if cfg.CertFilePath != "" || cfg.KeyFilePath != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFilePath, cfg.KeyFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load upstream client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if cfg.CAFilePath != "" {
+		pool, err := loadCertPool(cfg.CAFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load upstream CA bundle: %w", err)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	return tlsCfg, nil
+}
+
+// loadCertPool returns a pool of the PEM encoded certificates of a file.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pemCerts, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemCerts) {
+		return nil, fmt.Errorf("no PEM encoded certificate found in '%s'", path)
+	}
+	return pool, nil
+}
+
+// NewServerTLSConfig returns the TLS config of the proxy server. With client auth enabled, clients must present a
+// certificate issued by the configured CA and, if an allow-list is set, with one of its names.
+func NewServerTLSConfig(cfg config.SSLConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.CertFilePath, cfg.KeyFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+	tlsCfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	if !cfg.ClientAuth.Enable {
+		return tlsCfg, nil
+	}
+
+	pool, err := loadCertPool(cfg.ClientAuth.CAFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client CA bundle: %w", err)
+	}
+	tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	tlsCfg.ClientCAs = pool
+	if len(cfg.ClientAuth.AllowedNames) > 0 {
+		allowed := map[string]bool{}
+		for _, name := range cfg.ClientAuth.AllowedNames {
+			allowed[name] = true
+		}
+		tlsCfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			// The certificate chain is verified at this point, so there is a peer certificate
+			cert := cs.PeerCertificates[0]
+			for _, name := range certNames(cert) {
+				if allowed[name] {
+					return nil
+				}
+			}
+			log.WithField("client", ClientIdentity(cert)).Warn("client certificate rejected, none of its names is allowed")
+			return fmt.Errorf("client certificate '%s' is not allowed", ClientIdentity(cert))
+		}
+	}
+	return tlsCfg, nil
+}
+
+// ClientIdentity returns the name identifying the client of a certificate: its subject CN or, if not set,
+// its first SAN.
+func ClientIdentity(cert *x509.Certificate) string {
+	names := certNames(cert)
+	if len(names) == 0 {
+		return ""
+	}
+	return names[0]
+}
+
+// certNames returns the subject CN followed by the DNS, email and URI SANs of a certificate.
+func certNames(cert *x509.Certificate) []string {
+	var names []string
+	if cert.Subject.CommonName != "" {
+		names = append(names, cert.Subject.CommonName)
+	}
+	names = append(names, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+	return names
+}
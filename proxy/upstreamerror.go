@@ -0,0 +1,112 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Types of the upstream errors, reported in the error response and the upstream error metric.
+const (
+	UpstreamErrorDNS      = "dns"
+	UpstreamErrorDial     = "dial"
+	UpstreamErrorTLS      = "tls"
+	UpstreamErrorTimeout  = "timeout"
+	UpstreamErrorReset    = "reset"
+	UpstreamErrorCanceled = "canceled"
+	UpstreamErrorUnknown  = "unknown"
+)
+
+// StatusClientClosedRequest is the status of the requests cancelled by the client before the upstream target answered,
+// as used by nginx. The client is usually gone and does not receive it, it is logged in the request summary.
+const StatusClientClosedRequest = 499
+
+// NewUpstreamErrorHandler returns the error handler of the reverse proxy of a route. Failures to reach the upstream
+// target are logged, counted and answered with a JSON error, 504 for timeouts and 502 otherwise. Requests cancelled by
+// the client are not failures of the upstream target, they are answered with 499 without being counted.
+func NewUpstreamErrorHandler(route string, metricPublisher MetricPublisher) func(http.ResponseWriter, *http.Request, error) {
+	return func(w http.ResponseWriter, req *http.Request, err error) {
+		upstreamErr := &UpstreamError{errType: upstreamErrorType(err), reason: err}
+
+		fields := log.Fields{
+			"route":    route,
+			"method":   req.Method,
+			"upstream": req.URL.String(),
+			"type":     upstreamErr.Type(),
+			"error":    err.Error(),
+		}
+		status := http.StatusBadGateway
+		if upstreamErr.Type() == UpstreamErrorCanceled {
+			// The client is gone, the upstream target is not to blame
+			log.WithFields(fields).Debug("request cancelled by the client")
+			status = StatusClientClosedRequest
+		} else {
+			log.WithFields(fields).Error("failed to forward request to upstream target")
+			metricPublisher.IncrementUpstreamErrorCount(route, upstreamErr.Type())
+		}
+		if upstreamErr.Type() == UpstreamErrorTimeout {
+			status = http.StatusGatewayTimeout
+		}
+		body, _ := json.Marshal(map[string]string{
+			"error": upstreamErr.Error(),
+			"type":  upstreamErr.Type(),
+		})
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(status)
+		_, _ = w.Write(body)
+	}
+}
+
+// upstreamErrorType classifies the error returned by the transport to the upstream target.
+func upstreamErrorType(err error) string {
+	if errors.Is(err, context.Canceled) {
+		return UpstreamErrorCanceled
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return UpstreamErrorDNS
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return UpstreamErrorTimeout
+	}
+	if isTLSError(err) {
+		return UpstreamErrorTLS
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return UpstreamErrorReset
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return UpstreamErrorDial
+	}
+	return UpstreamErrorUnknown
+}
+
+// isTLSError reports whether the TLS handshake with the upstream target failed, either because its certificate could
+// not be verified or because it sent an alert, e.g. when it rejects the client certificate.
+func isTLSError(err error) bool {
+	var (
+		recordErr    tls.RecordHeaderError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+		opErr        *net.OpError
+	)
+	if errors.As(err, &recordErr) || errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) ||
+		errors.As(err, &invalidErr) {
+		return true
+	}
+	// Alerts sent by the upstream target are returned by crypto/tls as remote errors, the type of the alert itself is
+	// only exported since go 1.21
+	return errors.As(err, &opErr) && opErr.Op == "remote error"
+}
@@ -0,0 +1,159 @@
+package proxy
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	"github.com/form3tech-oss/http-message-signing-proxy/test"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpstreamErrorType(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{
+			"unknown host",
+			&net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "upstream.invalid"}},
+			UpstreamErrorDNS,
+		},
+		{
+			"connection refused",
+			&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)},
+			UpstreamErrorDial,
+		},
+		{
+			"deadline exceeded",
+			&url.Error{Op: "Get", URL: "http://upstream", Err: context.DeadlineExceeded},
+			UpstreamErrorTimeout,
+		},
+		{
+			"unknown certificate authority",
+			&url.Error{Op: "Get", URL: "https://upstream", Err: x509.UnknownAuthorityError{}},
+			UpstreamErrorTLS,
+		},
+		{
+			"expired certificate",
+			&url.Error{Op: "Get", URL: "https://upstream", Err: x509.CertificateInvalidError{Reason: x509.Expired}},
+			UpstreamErrorTLS,
+		},
+		{
+			"TLS alert",
+			&net.OpError{Op: "remote error", Err: errors.New("tls: bad certificate")},
+			UpstreamErrorTLS,
+		},
+		{
+			"cancelled by the client",
+			&url.Error{Op: "Get", URL: "http://upstream", Err: context.Canceled},
+			UpstreamErrorCanceled,
+		},
+		{
+			"connection reset",
+			&net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)},
+			UpstreamErrorReset,
+		},
+		{
+			"connection closed",
+			fmt.Errorf("failed to read response: %w", io.EOF),
+			UpstreamErrorReset,
+		},
+		{
+			"other error",
+			errors.New("something went wrong"),
+			UpstreamErrorUnknown,
+		},
+		{
+			"untyped error mentioning TLS",
+			errors.New("remote error: tls: bad certificate"),
+			UpstreamErrorUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, upstreamErrorType(tt.err))
+		})
+	}
+}
+
+func TestHandlerUpstreamError(t *testing.T) {
+	// Test upstream target that is not listening anymore
+	closedSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closedSrv.Close()
+
+	// Test upstream target slower than the response header timeout
+	slowSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer slowSrv.Close()
+
+	tests := []struct {
+		name           string
+		upstreamTarget string
+		cancel         bool
+		expectedStatus int
+		expectedType   string
+	}{
+		{"dial error", closedSrv.URL, false, http.StatusBadGateway, UpstreamErrorDial},
+		{"timeout", slowSrv.URL, false, http.StatusGatewayTimeout, UpstreamErrorTimeout},
+		{"cancelled by the client", slowSrv.URL, true, StatusClientClosedRequest, UpstreamErrorCanceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockMetricPublisher := mockMetricPublisher(mockCtrl, "test", http.MethodGet, "/payments/1")
+			// Upstream errors are counted by type, requests cancelled by the client are not upstream errors
+			if !tt.cancel {
+				mockMetricPublisher.EXPECT().IncrementUpstreamErrorCount("test", tt.expectedType).Times(1)
+			}
+
+			route, err := NewRoute(config.RouteConfig{
+				Name:           "test",
+				UpstreamTarget: tt.upstreamTarget,
+				Upstream: config.UpstreamConfig{
+					Transport: config.TransportConfig{ResponseHeaderTimeout: 50 * time.Millisecond},
+				},
+			}, mockReqSigner(mockCtrl), mockMetricPublisher)
+			require.NoError(t, err)
+
+			h := NewHandler([]*Route{route}, mockMetricPublisher)
+			_, e := gin.CreateTestContext(nil)
+			e.NoRoute(h.SelectRoute, LogAndMetricsMiddleware(mockMetricPublisher), h.ForwardRequest)
+
+			w := test.NewTestResponseRecorder()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				cancel()
+			}
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "/payments/1", nil)
+			require.NoError(t, err)
+			e.ServeHTTP(w, req)
+
+			require.Equal(t, tt.expectedStatus, w.Code)
+			require.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+			require.Regexp(t, fmt.Sprintf(`^{"error":"upstream %s error: .+","type":"%s"}$`, tt.expectedType, tt.expectedType), w.Body.String())
+		})
+	}
+}
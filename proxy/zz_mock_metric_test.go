@@ -33,62 +33,182 @@ func (m *MockMetricPublisher) EXPECT() *MockMetricPublisherMockRecorder {
 	return m.recorder
 }
 
+// IncrementClientRequestCount mocks base method.
+func (m *MockMetricPublisher) IncrementClientRequestCount(arg0, arg1 string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "IncrementClientRequestCount", arg0, arg1)
+}
+
+// IncrementClientRequestCount indicates an expected call of IncrementClientRequestCount.
+func (mr *MockMetricPublisherMockRecorder) IncrementClientRequestCount(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementClientRequestCount", reflect.TypeOf((*MockMetricPublisher)(nil).IncrementClientRequestCount), arg0, arg1)
+}
+
+// IncrementDeniedRequestCount mocks base method.
+func (m *MockMetricPublisher) IncrementDeniedRequestCount(arg0, arg1, arg2 string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "IncrementDeniedRequestCount", arg0, arg1, arg2)
+}
+
+// IncrementDeniedRequestCount indicates an expected call of IncrementDeniedRequestCount.
+func (mr *MockMetricPublisherMockRecorder) IncrementDeniedRequestCount(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementDeniedRequestCount", reflect.TypeOf((*MockMetricPublisher)(nil).IncrementDeniedRequestCount), arg0, arg1, arg2)
+}
+
 // IncrementInternalErrorCount mocks base method.
-func (m *MockMetricPublisher) IncrementInternalErrorCount(arg0, arg1 string) {
+func (m *MockMetricPublisher) IncrementInternalErrorCount(arg0, arg1, arg2 string) {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "IncrementInternalErrorCount", arg0, arg1)
+	m.ctrl.Call(m, "IncrementInternalErrorCount", arg0, arg1, arg2)
 }
 
 // IncrementInternalErrorCount indicates an expected call of IncrementInternalErrorCount.
-func (mr *MockMetricPublisherMockRecorder) IncrementInternalErrorCount(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockMetricPublisherMockRecorder) IncrementInternalErrorCount(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementInternalErrorCount", reflect.TypeOf((*MockMetricPublisher)(nil).IncrementInternalErrorCount), arg0, arg1, arg2)
+}
+
+// IncrementKeyReloadErrorCount mocks base method.
+func (m *MockMetricPublisher) IncrementKeyReloadErrorCount(arg0, arg1 string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "IncrementKeyReloadErrorCount", arg0, arg1)
+}
+
+// IncrementKeyReloadErrorCount indicates an expected call of IncrementKeyReloadErrorCount.
+func (mr *MockMetricPublisherMockRecorder) IncrementKeyReloadErrorCount(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementInternalErrorCount", reflect.TypeOf((*MockMetricPublisher)(nil).IncrementInternalErrorCount), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementKeyReloadErrorCount", reflect.TypeOf((*MockMetricPublisher)(nil).IncrementKeyReloadErrorCount), arg0, arg1)
+}
+
+// IncrementRateLimitedRequestCount mocks base method.
+func (m *MockMetricPublisher) IncrementRateLimitedRequestCount(arg0, arg1, arg2, arg3 string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "IncrementRateLimitedRequestCount", arg0, arg1, arg2, arg3)
+}
+
+// IncrementRateLimitedRequestCount indicates an expected call of IncrementRateLimitedRequestCount.
+func (mr *MockMetricPublisherMockRecorder) IncrementRateLimitedRequestCount(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementRateLimitedRequestCount", reflect.TypeOf((*MockMetricPublisher)(nil).IncrementRateLimitedRequestCount), arg0, arg1, arg2, arg3)
 }
 
 // IncrementSignedRequestCount mocks base method.
-func (m *MockMetricPublisher) IncrementSignedRequestCount(arg0, arg1 string) {
+func (m *MockMetricPublisher) IncrementSignedRequestCount(arg0, arg1, arg2 string) {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "IncrementSignedRequestCount", arg0, arg1)
+	m.ctrl.Call(m, "IncrementSignedRequestCount", arg0, arg1, arg2)
 }
 
 // IncrementSignedRequestCount indicates an expected call of IncrementSignedRequestCount.
-func (mr *MockMetricPublisherMockRecorder) IncrementSignedRequestCount(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockMetricPublisherMockRecorder) IncrementSignedRequestCount(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementSignedRequestCount", reflect.TypeOf((*MockMetricPublisher)(nil).IncrementSignedRequestCount), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementSignedRequestCount", reflect.TypeOf((*MockMetricPublisher)(nil).IncrementSignedRequestCount), arg0, arg1, arg2)
 }
 
 // IncrementTotalRequestCount mocks base method.
-func (m *MockMetricPublisher) IncrementTotalRequestCount(arg0, arg1 string) {
+func (m *MockMetricPublisher) IncrementTotalRequestCount(arg0, arg1, arg2 string) {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "IncrementTotalRequestCount", arg0, arg1)
+	m.ctrl.Call(m, "IncrementTotalRequestCount", arg0, arg1, arg2)
 }
 
 // IncrementTotalRequestCount indicates an expected call of IncrementTotalRequestCount.
-func (mr *MockMetricPublisherMockRecorder) IncrementTotalRequestCount(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockMetricPublisherMockRecorder) IncrementTotalRequestCount(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementTotalRequestCount", reflect.TypeOf((*MockMetricPublisher)(nil).IncrementTotalRequestCount), arg0, arg1, arg2)
+}
+
+// IncrementUpstreamErrorCount mocks base method.
+func (m *MockMetricPublisher) IncrementUpstreamErrorCount(arg0, arg1 string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "IncrementUpstreamErrorCount", arg0, arg1)
+}
+
+// IncrementUpstreamErrorCount indicates an expected call of IncrementUpstreamErrorCount.
+func (mr *MockMetricPublisherMockRecorder) IncrementUpstreamErrorCount(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementTotalRequestCount", reflect.TypeOf((*MockMetricPublisher)(nil).IncrementTotalRequestCount), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementUpstreamErrorCount", reflect.TypeOf((*MockMetricPublisher)(nil).IncrementUpstreamErrorCount), arg0, arg1)
+}
+
+// IncrementUpstreamResponseCount mocks base method.
+func (m *MockMetricPublisher) IncrementUpstreamResponseCount(arg0, arg1, arg2 string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "IncrementUpstreamResponseCount", arg0, arg1, arg2)
+}
+
+// IncrementUpstreamResponseCount indicates an expected call of IncrementUpstreamResponseCount.
+func (mr *MockMetricPublisherMockRecorder) IncrementUpstreamResponseCount(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementUpstreamResponseCount", reflect.TypeOf((*MockMetricPublisher)(nil).IncrementUpstreamResponseCount), arg0, arg1, arg2)
 }
 
 // MeasureSigningDuration mocks base method.
-func (m *MockMetricPublisher) MeasureSigningDuration(arg0, arg1 string, arg2 float64) {
+func (m *MockMetricPublisher) MeasureSigningDuration(arg0, arg1, arg2 string, arg3 float64) {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "MeasureSigningDuration", arg0, arg1, arg2)
+	m.ctrl.Call(m, "MeasureSigningDuration", arg0, arg1, arg2, arg3)
 }
 
 // MeasureSigningDuration indicates an expected call of MeasureSigningDuration.
-func (mr *MockMetricPublisherMockRecorder) MeasureSigningDuration(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockMetricPublisherMockRecorder) MeasureSigningDuration(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MeasureSigningDuration", reflect.TypeOf((*MockMetricPublisher)(nil).MeasureSigningDuration), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MeasureSigningDuration", reflect.TypeOf((*MockMetricPublisher)(nil).MeasureSigningDuration), arg0, arg1, arg2, arg3)
 }
 
 // MeasureTotalDuration mocks base method.
-func (m *MockMetricPublisher) MeasureTotalDuration(arg0, arg1 string, arg2 float64) {
+func (m *MockMetricPublisher) MeasureTotalDuration(arg0, arg1, arg2 string, arg3 int, arg4 float64) {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "MeasureTotalDuration", arg0, arg1, arg2)
+	m.ctrl.Call(m, "MeasureTotalDuration", arg0, arg1, arg2, arg3, arg4)
 }
 
 // MeasureTotalDuration indicates an expected call of MeasureTotalDuration.
-func (mr *MockMetricPublisherMockRecorder) MeasureTotalDuration(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockMetricPublisherMockRecorder) MeasureTotalDuration(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MeasureTotalDuration", reflect.TypeOf((*MockMetricPublisher)(nil).MeasureTotalDuration), arg0, arg1, arg2, arg3, arg4)
+}
+
+// MeasureUpstreamDuration mocks base method.
+func (m *MockMetricPublisher) MeasureUpstreamDuration(arg0, arg1, arg2 string, arg3 float64) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "MeasureUpstreamDuration", arg0, arg1, arg2, arg3)
+}
+
+// MeasureUpstreamDuration indicates an expected call of MeasureUpstreamDuration.
+func (mr *MockMetricPublisherMockRecorder) MeasureUpstreamDuration(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MeasureUpstreamDuration", reflect.TypeOf((*MockMetricPublisher)(nil).MeasureUpstreamDuration), arg0, arg1, arg2, arg3)
+}
+
+// SetActiveKey mocks base method.
+func (m *MockMetricPublisher) SetActiveKey(arg0, arg1 string, arg2 bool) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetActiveKey", arg0, arg1, arg2)
+}
+
+// SetActiveKey indicates an expected call of SetActiveKey.
+func (mr *MockMetricPublisherMockRecorder) SetActiveKey(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetActiveKey", reflect.TypeOf((*MockMetricPublisher)(nil).SetActiveKey), arg0, arg1, arg2)
+}
+
+// SetCircuitBreakerState mocks base method.
+func (m *MockMetricPublisher) SetCircuitBreakerState(arg0 string, arg1 CircuitState) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetCircuitBreakerState", arg0, arg1)
+}
+
+// SetCircuitBreakerState indicates an expected call of SetCircuitBreakerState.
+func (mr *MockMetricPublisherMockRecorder) SetCircuitBreakerState(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetCircuitBreakerState", reflect.TypeOf((*MockMetricPublisher)(nil).SetCircuitBreakerState), arg0, arg1)
+}
+
+// SetSecondsUntilKeyRotation mocks base method.
+func (m *MockMetricPublisher) SetSecondsUntilKeyRotation(arg0, arg1 string, arg2 float64) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetSecondsUntilKeyRotation", arg0, arg1, arg2)
+}
+
+// SetSecondsUntilKeyRotation indicates an expected call of SetSecondsUntilKeyRotation.
+func (mr *MockMetricPublisherMockRecorder) SetSecondsUntilKeyRotation(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MeasureTotalDuration", reflect.TypeOf((*MockMetricPublisher)(nil).MeasureTotalDuration), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSecondsUntilKeyRotation", reflect.TypeOf((*MockMetricPublisher)(nil).SetSecondsUntilKeyRotation), arg0, arg1, arg2)
 }
@@ -0,0 +1,106 @@
+package signer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // register SHA-256 for crypto.Hash.New
+	_ "crypto/sha512" // register SHA-384 and SHA-512 for crypto.Hash.New
+	"fmt"
+	"io"
+	"strings"
+
+	msgsigner "github.com/form3tech-oss/go-http-message-signatures"
+)
+
+const (
+	rsaName     = "rsa"
+	ecdsaName   = "ecdsa"
+	ed25519Name = "ed25519"
+)
+
+// cryptoSigner signs content with a crypto.Signer. The content is hashed first unless hash is 0,
+// which is the case for algorithms such as Ed25519 that sign the whole message.
+type cryptoSigner struct {
+	key  crypto.Signer
+	hash crypto.Hash
+	name string
+}
+
+// compile time check that cryptoSigner implements msgsigner.Signer.
+var _ msgsigner.Signer = &cryptoSigner{}
+
+func (s *cryptoSigner) Sign(rand io.Reader, content []byte) ([]byte, error) {
+	digest := content
+	if s.hash != 0 {
+		hasher := s.hash.New()
+		if _, err := hasher.Write(content); err != nil {
+			return nil, fmt.Errorf("failed to hash content: %w", err)
+		}
+		digest = hasher.Sum(nil)
+	}
+	return s.key.Sign(rand, digest, s.hash)
+}
+
+func (s *cryptoSigner) String() string {
+	return s.name
+}
+
+// newAlgorithmSigner returns the signer matching the type of the key. RSA keys can be used with SHA-256 or SHA-512,
+// ECDSA keys must be used with the hash that matches their curve and Ed25519 keys with SHA-512.
+func newAlgorithmSigner(key crypto.Signer, hash crypto.Hash) (msgsigner.Signer, error) {
+	switch pub := key.Public().(type) {
+	case *rsa.PublicKey:
+		if hash != crypto.SHA256 && hash != crypto.SHA512 {
+			return nil, fmt.Errorf("signature hash algo '%s' cannot be used with RSA key, expected '%s' or '%s'", hash, crypto.SHA256, crypto.SHA512)
+		}
+		return &cryptoSigner{
+			key:  key,
+			hash: hash,
+			name: rsaName + "-" + getHashName(hash),
+		}, nil
+	case *ecdsa.PublicKey:
+		curveHash, err := getCurveHash(pub.Curve)
+		if err != nil {
+			return nil, err
+		}
+		if hash != curveHash {
+			return nil, fmt.Errorf("signature hash algo '%s' cannot be used with ECDSA %s key, expected '%s'", hash, pub.Curve.Params().Name, curveHash)
+		}
+		return &cryptoSigner{
+			key:  key,
+			hash: hash,
+			name: ecdsaName + "-" + getHashName(hash),
+		}, nil
+	case ed25519.PublicKey:
+		if hash != crypto.SHA512 {
+			return nil, fmt.Errorf("signature hash algo '%s' cannot be used with Ed25519 key, expected '%s'", hash, crypto.SHA512)
+		}
+		return &cryptoSigner{
+			key:  key,
+			name: ed25519Name,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported signer key type %T", pub)
+	}
+}
+
+func getCurveHash(curve elliptic.Curve) (crypto.Hash, error) {
+	switch curve {
+	case elliptic.P256():
+		return crypto.SHA256, nil
+	case elliptic.P384():
+		return crypto.SHA384, nil
+	case elliptic.P521():
+		return crypto.SHA512, nil
+	default:
+		return 0, fmt.Errorf("unsupported ECDSA curve '%s'", curve.Params().Name)
+	}
+}
+
+// getHashName returns the hash name in the format used by the signature algorithm, e.g. sha256.
+func getHashName(hash crypto.Hash) string {
+	return strings.ToLower(strings.ReplaceAll(hash.String(), "-", ""))
+}
@@ -0,0 +1,134 @@
+package signer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAlgorithmSigner(t *testing.T) {
+	tests := []struct {
+		name         string
+		keyFile      string
+		hash         crypto.Hash
+		expectedName string
+		errCheckFn   errCheckFn
+	}{
+		{
+			"rsa with sha-256",
+			"rsa_test.pem",
+			crypto.SHA256,
+			"rsa-sha256",
+			require.NoError,
+		},
+		{
+			"rsa with sha-384 is unsupported",
+			"rsa_test.pem",
+			crypto.SHA384,
+			"",
+			require.Error,
+		},
+		{
+			"ecdsa p-256 with sha-256",
+			"ecdsa_p256_test.pem",
+			crypto.SHA256,
+			"ecdsa-sha256",
+			require.NoError,
+		},
+		{
+			"ecdsa p-256 with sha-512",
+			"ecdsa_p256_test.pem",
+			crypto.SHA512,
+			"",
+			require.Error,
+		},
+		{
+			"ecdsa p-384 with sha-384",
+			"ecdsa_p384_test.pem",
+			crypto.SHA384,
+			"ecdsa-sha384",
+			require.NoError,
+		},
+		{
+			"ecdsa p-384 with sha-256",
+			"ecdsa_p384_test.pem",
+			crypto.SHA256,
+			"",
+			require.Error,
+		},
+		{
+			"ed25519 with sha-512",
+			"ed25519_test.pem",
+			crypto.SHA512,
+			"ed25519",
+			require.NoError,
+		},
+		{
+			"ed25519 with sha-256",
+			"ed25519_test.pem",
+			crypto.SHA256,
+			"",
+			require.Error,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := loadKey(test.keyFile, nil)
+			require.NoError(t, err)
+
+			signer, err := newAlgorithmSigner(key, test.hash)
+			test.errCheckFn(t, err)
+			if err == nil {
+				require.Equal(t, test.expectedName, signer.String())
+			}
+		})
+	}
+}
+
+func TestCryptoSignerSign(t *testing.T) {
+	content := []byte("(request-target): get /v1/organisation/accounts\nhost: api.form3.tech")
+
+	t.Run("ecdsa", func(t *testing.T) {
+		key, err := loadKey("ecdsa_p384_test.pem", nil)
+		require.NoError(t, err)
+		signer, err := newAlgorithmSigner(key, crypto.SHA384)
+		require.NoError(t, err)
+
+		signature, err := signer.Sign(rand.Reader, content)
+		require.NoError(t, err)
+
+		hasher := crypto.SHA384.New()
+		hasher.Write(content)
+		ecdsaKey := key.(*ecdsa.PrivateKey)
+		require.True(t, ecdsa.VerifyASN1(&ecdsaKey.PublicKey, hasher.Sum(nil), signature))
+	})
+
+	t.Run("ed25519", func(t *testing.T) {
+		key, err := loadKey("ed25519_test.pem", nil)
+		require.NoError(t, err)
+		signer, err := newAlgorithmSigner(key, crypto.SHA512)
+		require.NoError(t, err)
+
+		signature, err := signer.Sign(rand.Reader, content)
+		require.NoError(t, err)
+
+		ed25519Key := key.(ed25519.PrivateKey)
+		require.True(t, ed25519.Verify(ed25519Key.Public().(ed25519.PublicKey), content, signature))
+	})
+}
+
+func TestNewRequestSignerKeyAndHashMismatch(t *testing.T) {
+	_, err := NewRequestSigner("test", config.SignerConfig{
+		KeyId:             "dfb4c78a-e141-4144-aa68-8ec605484d63",
+		KeyFilePath:       "ecdsa_p256_test.pem",
+		BodyDigestAlgo:    "SHA-256",
+		SignatureHashAlgo: "SHA-384",
+	}, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,99 @@
+package signer
+
+import "fmt"
+
+// KeyFileError is raised when the signer key file or its passphrase file cannot be read.
+type KeyFileError struct {
+	path string
+	err  error
+}
+
+func NewKeyFileError(path string, err error) error {
+	return &KeyFileError{
+		path: path,
+		err:  err,
+	}
+}
+
+func (e *KeyFileError) Error() string {
+	return fmt.Sprintf("failed to read file '%s': %s", e.path, e.err.Error())
+}
+
+func (e *KeyFileError) Unwrap() error {
+	return e.err
+}
+
+// KeyFormatError is raised when the signer key cannot be decoded or is not in a supported format.
+type KeyFormatError struct {
+	message string
+	err     error
+}
+
+func NewKeyFormatError(message string, err error) error {
+	return &KeyFormatError{
+		message: message,
+		err:     err,
+	}
+}
+
+func (e *KeyFormatError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("invalid signer key: %s", e.message)
+	}
+	return fmt.Sprintf("invalid signer key: %s: %s", e.message, e.err.Error())
+}
+
+func (e *KeyFormatError) Unwrap() error {
+	return e.err
+}
+
+// KeyPassphraseError is raised when an encrypted signer key cannot be decrypted,
+// either because no passphrase is configured or because it is incorrect.
+type KeyPassphraseError struct {
+	message string
+	err     error
+}
+
+func NewKeyPassphraseError(message string, err error) error {
+	return &KeyPassphraseError{
+		message: message,
+		err:     err,
+	}
+}
+
+func (e *KeyPassphraseError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("signer key passphrase: %s", e.message)
+	}
+	return fmt.Sprintf("signer key passphrase: %s: %s", e.message, e.err.Error())
+}
+
+func (e *KeyPassphraseError) Unwrap() error {
+	return e.err
+}
+
+// KeyProviderError is raised when a key provider fails to get a key or to sign with it.
+type KeyProviderError struct {
+	provider string
+	message  string
+	err      error
+}
+
+func NewKeyProviderError(provider string, message string, err error) error {
+	return &KeyProviderError{
+		provider: provider,
+		message:  message,
+		err:      err,
+	}
+}
+
+func (e *KeyProviderError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("%s key provider: %s", e.provider, e.message)
+	}
+	return fmt.Sprintf("%s key provider: %s: %s", e.provider, e.message, e.err.Error())
+}
+
+func (e *KeyProviderError) Unwrap() error {
+	return e.err
+}
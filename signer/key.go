@@ -0,0 +1,135 @@
+package signer
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	"github.com/youmark/pkcs8"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	encryptedPKCS8BlockType = "ENCRYPTED PRIVATE KEY"
+	openSSHBlockType        = "OPENSSH PRIVATE KEY"
+	dekInfoHeader           = "DEK-Info"
+)
+
+// loadKey reads a PEM encoded private key from keyFile. PKCS#1, PKCS#8, SEC1 and OpenSSH keys are
+// detected automatically. Encrypted keys are decrypted with passphrase.
+func loadKey(keyFile string, passphrase []byte) (crypto.Signer, error) {
+	rawKey, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, NewKeyFileError(keyFile, err)
+	}
+	return parseKey(rawKey, passphrase)
+}
+
+func parseKey(rawKey []byte, passphrase []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(rawKey)
+	if block == nil {
+		return nil, NewKeyFormatError("no PEM encoded key found", nil)
+	}
+
+	var (
+		key crypto.PrivateKey
+		err error
+	)
+	_, isLegacyEncrypted := block.Headers[dekInfoHeader]
+	switch {
+	case block.Type == encryptedPKCS8BlockType:
+		key, err = parseEncryptedPKCS8Key(block.Bytes, passphrase)
+	case block.Type == openSSHBlockType || isLegacyEncrypted:
+		key, err = parseSSHKey(rawKey, passphrase)
+	default:
+		key, err = parseDERKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// OpenSSH Ed25519 keys are returned as a pointer
+	if k, ok := key.(*ed25519.PrivateKey); ok {
+		key = *k
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, NewKeyFormatError(fmt.Sprintf("unsupported key type %T", key), nil)
+	}
+	return signer, nil
+}
+
+// parseDERKey tries every unencrypted DER format, as PEM block types are not always accurate.
+func parseDERKey(der []byte) (crypto.PrivateKey, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	return nil, NewKeyFormatError("key is neither a PKCS#8, PKCS#1 nor SEC1 private key", nil)
+}
+
+func parseEncryptedPKCS8Key(der []byte, passphrase []byte) (crypto.PrivateKey, error) {
+	if len(passphrase) == 0 {
+		return nil, NewKeyPassphraseError("key is encrypted but no passphrase is configured", nil)
+	}
+	key, err := pkcs8.ParsePKCS8PrivateKey(der, passphrase)
+	if err != nil {
+		return nil, NewKeyPassphraseError("failed to decrypt PKCS#8 key", err)
+	}
+	return key, nil
+}
+
+// parseSSHKey parses OpenSSH keys and legacy OpenSSL encrypted PEM keys (Proc-Type: 4,ENCRYPTED).
+func parseSSHKey(rawKey []byte, passphrase []byte) (crypto.PrivateKey, error) {
+	key, err := ssh.ParseRawPrivateKey(rawKey)
+
+	var passphraseMissingErr *ssh.PassphraseMissingError
+	if errors.As(err, &passphraseMissingErr) {
+		if len(passphrase) == 0 {
+			return nil, NewKeyPassphraseError("key is encrypted but no passphrase is configured", nil)
+		}
+		key, err = ssh.ParseRawPrivateKeyWithPassphrase(rawKey, passphrase)
+		if errors.Is(err, x509.IncorrectPasswordError) {
+			return nil, NewKeyPassphraseError("incorrect passphrase", nil)
+		}
+	}
+	if err != nil {
+		return nil, NewKeyFormatError("failed to parse key", err)
+	}
+	return key, nil
+}
+
+// getKeyPassphrase returns the passphrase of the signer key, taken from the first source that is configured.
+// A nil passphrase means the key is not encrypted.
+func getKeyPassphrase(cfg config.SignerConfig) ([]byte, error) {
+	switch {
+	case cfg.KeyPassphrase != "":
+		return []byte(cfg.KeyPassphrase), nil
+	case cfg.KeyPassphraseEnvVar != "":
+		passphrase, ok := os.LookupEnv(cfg.KeyPassphraseEnvVar)
+		if !ok {
+			return nil, NewKeyPassphraseError(fmt.Sprintf("env var '%s' is not set", cfg.KeyPassphraseEnvVar), nil)
+		}
+		return []byte(passphrase), nil
+	case cfg.KeyPassphraseFilePath != "":
+		rawPassphrase, err := os.ReadFile(cfg.KeyPassphraseFilePath)
+		if err != nil {
+			return nil, NewKeyFileError(cfg.KeyPassphraseFilePath, err)
+		}
+		// Files written by editors or `echo` usually end with a newline that is not part of the passphrase
+		return []byte(strings.TrimRight(string(rawPassphrase), "\r\n")), nil
+	default:
+		return nil, nil
+	}
+}
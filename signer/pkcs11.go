@@ -0,0 +1,71 @@
+//go:build pkcs11
+
+package signer
+
+import (
+	"crypto"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ThalesIgnite/crypto11"
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+)
+
+// pkcs11KeyProvider signs with keys held by a PKCS#11 token, such as an HSM. Keys are looked up by their label,
+// which must be the key id.
+type pkcs11KeyProvider struct {
+	ctx *crypto11.Context
+}
+
+func newPKCS11KeyProvider(cfg config.PKCS11Config) (KeyProvider, error) {
+	pin := cfg.Pin
+	if cfg.PinEnvVar != "" {
+		var ok bool
+		pin, ok = os.LookupEnv(cfg.PinEnvVar)
+		if !ok {
+			return nil, NewKeyProviderError(pkcs11Provider, fmt.Sprintf("env var '%s' is not set", cfg.PinEnvVar), nil)
+		}
+	}
+
+	ctx, err := crypto11.Configure(&crypto11.Config{
+		Path:       cfg.ModulePath,
+		TokenLabel: cfg.TokenLabel,
+		Pin:        pin,
+	})
+	if err != nil {
+		return nil, NewKeyProviderError(pkcs11Provider, fmt.Sprintf("failed to open token '%s'", cfg.TokenLabel), err)
+	}
+
+	return &pkcs11KeyProvider{
+		ctx: ctx,
+	}, nil
+}
+
+func (p *pkcs11KeyProvider) GetSigner(keyCfg config.KeyConfig) (crypto.Signer, error) {
+	key, err := p.ctx.FindKeyPair(nil, []byte(keyCfg.KeyId))
+	if err != nil {
+		return nil, NewKeyProviderError(pkcs11Provider, fmt.Sprintf("failed to find key '%s'", keyCfg.KeyId), err)
+	}
+	if key == nil {
+		return nil, NewKeyProviderError(pkcs11Provider, fmt.Sprintf("key '%s' not found", keyCfg.KeyId), nil)
+	}
+	return &pkcs11Signer{key}, nil
+}
+
+func (p *pkcs11KeyProvider) Close() error {
+	return p.ctx.Close()
+}
+
+// pkcs11Signer wraps the signing errors of the token, which are not caused by the request being signed.
+type pkcs11Signer struct {
+	crypto.Signer
+}
+
+func (s *pkcs11Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	signature, err := s.Signer.Sign(rand, digest, opts)
+	if err != nil {
+		return nil, NewKeyProviderError(pkcs11Provider, "failed to sign", err)
+	}
+	return signature, nil
+}
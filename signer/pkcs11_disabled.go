@@ -0,0 +1,12 @@
+//go:build !pkcs11
+
+package signer
+
+import (
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+)
+
+// newPKCS11KeyProvider fails unless the proxy is built with the pkcs11 build tag, as PKCS#11 support requires cgo.
+func newPKCS11KeyProvider(_ config.PKCS11Config) (KeyProvider, error) {
+	return nil, NewKeyProviderError(pkcs11Provider, "not supported by this build, build with '-tags pkcs11'", nil)
+}
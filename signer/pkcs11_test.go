@@ -0,0 +1,87 @@
+//go:build pkcs11
+
+package signer
+
+import (
+	"crypto/rand"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/ThalesIgnite/crypto11"
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPKCS11KeyProvider runs against an initialised SoftHSM token, e.g.:
+//
+//	softhsm2-util --init-token --free --label proxy-test --pin 1234 --so-pin 1234
+//	PKCS11_MODULE_PATH=/usr/lib/softhsm/libsofthsm2.so PKCS11_TOKEN_LABEL=proxy-test PKCS11_PIN=1234 \
+//	  go test -tags pkcs11 ./signer/
+func TestPKCS11KeyProvider(t *testing.T) {
+	modulePath := os.Getenv("PKCS11_MODULE_PATH")
+	if modulePath == "" {
+		t.Skip("PKCS11_MODULE_PATH is not set")
+	}
+	pkcs11Cfg := config.PKCS11Config{
+		ModulePath: modulePath,
+		TokenLabel: os.Getenv("PKCS11_TOKEN_LABEL"),
+		Pin:        os.Getenv("PKCS11_PIN"),
+	}
+
+	ctx, err := crypto11.Configure(&crypto11.Config{
+		Path:       pkcs11Cfg.ModulePath,
+		TokenLabel: pkcs11Cfg.TokenLabel,
+		Pin:        pkcs11Cfg.Pin,
+	})
+	require.NoError(t, err)
+	id := make([]byte, 16)
+	_, err = rand.Read(id)
+	require.NoError(t, err)
+	keyId := "pkcs11-test-" + t.Name()
+	key, err := ctx.GenerateRSAKeyPairWithLabel(id, []byte(keyId), 2048)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, key.Delete())
+		require.NoError(t, ctx.Close())
+	}()
+
+	for _, profile := range []string{cavageProfile, rfc9421Profile} {
+		t.Run(profile, func(t *testing.T) {
+			reqSigner, err := NewRequestSigner("test", config.SignerConfig{
+				KeyId:             keyId,
+				KeyProvider:       pkcs11Provider,
+				PKCS11:            pkcs11Cfg,
+				BodyDigestAlgo:    "SHA-256",
+				SignatureHashAlgo: "SHA-256",
+				Profile:           profile,
+				Headers: config.HeadersConfig{
+					SignatureHeaders: []string{"date"},
+				},
+				RFC9421: config.RFC9421Config{
+					Components: []string{"@method", "date"},
+				},
+			}, nil)
+			require.NoError(t, err)
+			defer reqSigner.(*requestSigner).Close()
+
+			req, err := http.NewRequest(http.MethodGet, "https://localhost:1234", nil)
+			require.NoError(t, err)
+			req.Header.Set("Date", "Tue, 20 Apr 2021 02:07:55 GMT")
+			_, err = reqSigner.SignRequest(req)
+			require.NoError(t, err)
+		})
+	}
+
+	t.Run("unknown key", func(t *testing.T) {
+		_, err := NewRequestSigner("test", config.SignerConfig{
+			KeyId:             "unknown",
+			KeyProvider:       pkcs11Provider,
+			PKCS11:            pkcs11Cfg,
+			BodyDigestAlgo:    "SHA-256",
+			SignatureHashAlgo: "SHA-256",
+		}, nil)
+		var providerErr *KeyProviderError
+		require.ErrorAs(t, err, &providerErr)
+	})
+}
@@ -0,0 +1,61 @@
+package signer
+
+import (
+	"crypto"
+	"fmt"
+	"strings"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+)
+
+const (
+	fileProvider   = "file"
+	remoteProvider = "remote"
+	pkcs11Provider = "pkcs11"
+)
+
+// KeyProvider gives access to the signing keys. Only the file key provider holds the private keys in memory,
+// other providers sign through an external service or device and the private keys never leave it.
+type KeyProvider interface {
+	// GetSigner returns the signer of the given key.
+	GetSigner(keyCfg config.KeyConfig) (crypto.Signer, error)
+	// Close releases the resources held by the provider.
+	Close() error
+}
+
+func newKeyProvider(cfg config.SignerConfig) (KeyProvider, error) {
+	switch strings.ToLower(cfg.KeyProvider) {
+	case fileProvider, "":
+		return newFileKeyProvider(cfg), nil
+	case remoteProvider:
+		return newRemoteKeyProvider(cfg.Remote)
+	case pkcs11Provider:
+		return newPKCS11KeyProvider(cfg.PKCS11)
+	default:
+		return nil, fmt.Errorf("unknown key provider '%s'", cfg.KeyProvider)
+	}
+}
+
+// fileKeyProvider reads the keys from PEM encoded key files, decrypted with the passphrase of the signer config.
+type fileKeyProvider struct {
+	cfg config.SignerConfig
+}
+
+func newFileKeyProvider(cfg config.SignerConfig) KeyProvider {
+	return &fileKeyProvider{
+		cfg: cfg,
+	}
+}
+
+func (p *fileKeyProvider) GetSigner(keyCfg config.KeyConfig) (crypto.Signer, error) {
+	// The passphrase is read every time, so that it can be rotated along with the key file
+	passphrase, err := getKeyPassphrase(p.cfg)
+	if err != nil {
+		return nil, err
+	}
+	return loadKey(keyCfg.KeyFilePath, passphrase)
+}
+
+func (p *fileKeyProvider) Close() error {
+	return nil
+}
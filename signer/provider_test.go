@@ -0,0 +1,45 @@
+package signer
+
+import (
+	"testing"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewKeyProvider(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        config.SignerConfig
+		errCheckFn errCheckFn
+	}{
+		{
+			"file by default",
+			config.SignerConfig{},
+			require.NoError,
+		},
+		{
+			"remote",
+			config.SignerConfig{
+				KeyProvider: "Remote",
+				Remote:      config.RemoteConfig{URL: "https://kms.example.com"},
+			},
+			require.NoError,
+		},
+		{
+			"unknown",
+			config.SignerConfig{KeyProvider: "vault"},
+			require.Error,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider, err := newKeyProvider(test.cfg)
+			test.errCheckFn(t, err)
+			if err == nil {
+				require.NoError(t, provider.Close())
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package signer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+)
+
+const (
+	defaultRemoteTimeout = 5 * time.Second
+
+	pkcs1v15Padding = "pkcs1v15"
+	pssPadding      = "pss"
+)
+
+// remoteKeyResponse is returned by GET {url}/keys/{keyId}.
+type remoteKeyResponse struct {
+	// PublicKey is the PEM encoded PKIX public key.
+	PublicKey string `json:"publicKey"`
+}
+
+// remoteSignRequest is sent to POST {url}/keys/{keyId}/sign. Data is the digest to sign, or the whole
+// message for Ed25519 keys, in which case Hash is empty. Byte slices are base64 encoded.
+type remoteSignRequest struct {
+	Data []byte `json:"data"`
+	Hash string `json:"hash,omitempty"`
+	// Padding is set for RSA keys only, either pkcs1v15 or pss
+	Padding    string `json:"padding,omitempty"`
+	SaltLength int    `json:"saltLength,omitempty"`
+}
+
+// remoteSignResponse is returned by POST {url}/keys/{keyId}/sign. ECDSA signatures are ASN.1 encoded.
+type remoteSignResponse struct {
+	Signature []byte `json:"signature"`
+}
+
+// remoteKeyProvider signs with keys held by a remote signing service, such as a KMS behind a small HTTP facade.
+type remoteKeyProvider struct {
+	url    string
+	client *http.Client
+}
+
+func newRemoteKeyProvider(cfg config.RemoteConfig) (KeyProvider, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, NewKeyProviderError(remoteProvider, fmt.Sprintf("invalid url '%s'", cfg.URL), err)
+	}
+
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = defaultRemoteTimeout
+	}
+
+	return &remoteKeyProvider{
+		url: strings.TrimSuffix(cfg.URL, "/"),
+		client: &http.Client{
+			Timeout: timeout,
+		},
+	}, nil
+}
+
+func (p *remoteKeyProvider) GetSigner(keyCfg config.KeyConfig) (crypto.Signer, error) {
+	var keyResp remoteKeyResponse
+	if err := p.do(http.MethodGet, p.getKeyURL(keyCfg.KeyId), nil, &keyResp); err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode([]byte(keyResp.PublicKey))
+	if block == nil {
+		return nil, NewKeyProviderError(remoteProvider, fmt.Sprintf("no PEM encoded public key found for key '%s'", keyCfg.KeyId), nil)
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, NewKeyProviderError(remoteProvider, fmt.Sprintf("invalid public key for key '%s'", keyCfg.KeyId), err)
+	}
+
+	return &remoteSigner{
+		provider: p,
+		keyId:    keyCfg.KeyId,
+		public:   pub,
+	}, nil
+}
+
+func (p *remoteKeyProvider) Close() error {
+	p.client.CloseIdleConnections()
+	return nil
+}
+
+func (p *remoteKeyProvider) getKeyURL(keyId string) string {
+	return p.url + "/keys/" + url.PathEscape(keyId)
+}
+
+// do sends body as JSON, if any, and decodes the JSON response into out.
+func (p *remoteKeyProvider) do(method string, url string, body interface{}, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		rawBody, err := json.Marshal(body)
+		if err != nil {
+			return NewKeyProviderError(remoteProvider, "failed to encode request", err)
+		}
+		reqBody = bytes.NewReader(rawBody)
+	}
+
+	req, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		return NewKeyProviderError(remoteProvider, "failed to create request", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return NewKeyProviderError(remoteProvider, "request failed", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return NewKeyProviderError(remoteProvider, fmt.Sprintf("unexpected status code %d from %s %s", resp.StatusCode, method, url), nil)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return NewKeyProviderError(remoteProvider, "failed to decode response", err)
+	}
+	return nil
+}
+
+// remoteSigner is a crypto.Signer that sends every signing operation to the remote signing service.
+type remoteSigner struct {
+	provider *remoteKeyProvider
+	keyId    string
+	public   crypto.PublicKey
+}
+
+func (s *remoteSigner) Public() crypto.PublicKey {
+	return s.public
+}
+
+func (s *remoteSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	signReq := remoteSignRequest{
+		Data: digest,
+	}
+	if hash := opts.HashFunc(); hash != 0 {
+		signReq.Hash = hash.String()
+	}
+	if _, ok := s.public.(*rsa.PublicKey); ok {
+		signReq.Padding = pkcs1v15Padding
+		if pssOpts, ok := opts.(*rsa.PSSOptions); ok {
+			signReq.Padding = pssPadding
+			signReq.SaltLength = pssOpts.SaltLength
+		}
+	}
+
+	var signResp remoteSignResponse
+	if err := s.provider.do(http.MethodPost, s.provider.getKeyURL(s.keyId)+"/sign", signReq, &signResp); err != nil {
+		return nil, err
+	}
+	return signResp.Signature, nil
+}
@@ -0,0 +1,221 @@
+package signer
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	"github.com/form3tech-oss/http-message-signing-proxy/proxy"
+	"github.com/stretchr/testify/require"
+)
+
+// newFakeSigningService returns a remote signing service signing with the given key files, indexed by key id.
+func newFakeSigningService(t *testing.T, keyFiles map[string]string) *httptest.Server {
+	keys := map[string]crypto.Signer{}
+	for keyId, keyFile := range keyFiles {
+		key, err := loadKey(keyFile, nil)
+		require.NoError(t, err)
+		keys[keyId] = key
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/keys/")
+		key, ok := keys[strings.TrimSuffix(path, "/sign")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if r.Method == http.MethodGet {
+			der, err := x509.MarshalPKIXPublicKey(key.Public())
+			require.NoError(t, err)
+			_ = json.NewEncoder(w).Encode(remoteKeyResponse{
+				PublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+			})
+			return
+		}
+
+		var signReq remoteSignRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&signReq))
+		var opts crypto.SignerOpts = crypto.Hash(0)
+		if signReq.Hash != "" {
+			hash, err := getHashAlgo(signReq.Hash)
+			require.NoError(t, err)
+			opts = hash
+		}
+		if signReq.Padding == pssPadding {
+			opts = &rsa.PSSOptions{SaltLength: signReq.SaltLength, Hash: opts.HashFunc()}
+		}
+		signature, err := key.Sign(rand.Reader, signReq.Data, opts)
+		require.NoError(t, err)
+		_ = json.NewEncoder(w).Encode(remoteSignResponse{Signature: signature})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRemoteKeyProvider(t *testing.T) {
+	server := newFakeSigningService(t, map[string]string{
+		"rsa":     "rsa_test.pem",
+		"ecdsa":   "ecdsa_p256_test.pem",
+		"ed25519": "ed25519_test.pem",
+	})
+
+	tests := []struct {
+		name              string
+		keyId             string
+		profile           string
+		signatureHashAlgo string
+	}{
+		{
+			"rsa cavage",
+			"rsa",
+			cavageProfile,
+			"SHA-256",
+		},
+		{
+			"rsa-pss rfc9421",
+			"rsa",
+			rfc9421Profile,
+			"SHA-512",
+		},
+		{
+			"ecdsa rfc9421",
+			"ecdsa",
+			rfc9421Profile,
+			"SHA-256",
+		},
+		{
+			"ed25519 cavage",
+			"ed25519",
+			cavageProfile,
+			"SHA-512",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reqSigner, err := NewRequestSigner("test", config.SignerConfig{
+				KeyId:             test.keyId,
+				KeyProvider:       remoteProvider,
+				Remote:            config.RemoteConfig{URL: server.URL},
+				BodyDigestAlgo:    "SHA-256",
+				SignatureHashAlgo: test.signatureHashAlgo,
+				Profile:           test.profile,
+				Headers: config.HeadersConfig{
+					SignatureHeaders: []string{"date"},
+				},
+				RFC9421: config.RFC9421Config{
+					Components: []string{"@method", "date"},
+				},
+			}, nil)
+			require.NoError(t, err)
+			defer reqSigner.(*requestSigner).Close()
+
+			req, err := http.NewRequest(http.MethodGet, "https://localhost:1234", nil)
+			require.NoError(t, err)
+			req.Header.Set("Date", "Tue, 20 Apr 2021 02:07:55 GMT")
+
+			_, err = reqSigner.SignRequest(req)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestRemoteKeyProviderSignature(t *testing.T) {
+	// RSA PKCS#1 v1.5 signatures are deterministic, so the remote signature must match the local one
+	server := newFakeSigningService(t, map[string]string{"rsa": "rsa_test.pem"})
+	cfg := config.SignerConfig{
+		KeyId:             "rsa",
+		KeyFilePath:       "rsa_test.pem",
+		BodyDigestAlgo:    "SHA-256",
+		SignatureHashAlgo: "SHA-256",
+		Headers: config.HeadersConfig{
+			SignatureHeaders: []string{"date"},
+		},
+	}
+
+	var signatures []string
+	for _, provider := range []string{fileProvider, remoteProvider} {
+		cfg.KeyProvider = provider
+		cfg.Remote.URL = server.URL
+		reqSigner, err := NewRequestSigner("test", cfg, nil)
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodGet, "https://localhost:1234", nil)
+		require.NoError(t, err)
+		req.Header.Set("Date", "Tue, 20 Apr 2021 02:07:55 GMT")
+		signedReq, err := reqSigner.SignRequest(req)
+		require.NoError(t, err)
+		signatures = append(signatures, signedReq.Header.Get("Authorization"))
+	}
+	require.Equal(t, signatures[0], signatures[1])
+}
+
+func TestRemoteKeyProviderErrors(t *testing.T) {
+	server := newFakeSigningService(t, map[string]string{"rsa": "rsa_test.pem"})
+	cfg := config.SignerConfig{
+		KeyId:             "unknown",
+		KeyProvider:       remoteProvider,
+		Remote:            config.RemoteConfig{URL: server.URL},
+		BodyDigestAlgo:    "SHA-256",
+		SignatureHashAlgo: "SHA-256",
+		Headers: config.HeadersConfig{
+			SignatureHeaders: []string{"date"},
+		},
+	}
+
+	t.Run("unknown key", func(t *testing.T) {
+		_, err := NewRequestSigner("test", cfg, nil)
+		var providerErr *KeyProviderError
+		require.ErrorAs(t, err, &providerErr)
+	})
+
+	t.Run("invalid url", func(t *testing.T) {
+		invalidCfg := cfg
+		invalidCfg.Remote.URL = "localhost:1234"
+		_, err := NewRequestSigner("test", invalidCfg, nil)
+		var providerErr *KeyProviderError
+		require.ErrorAs(t, err, &providerErr)
+	})
+
+	t.Run("signing service unavailable", func(t *testing.T) {
+		cfg.KeyId = "rsa"
+		reqSigner, err := NewRequestSigner("test", cfg, nil)
+		require.NoError(t, err)
+		server.Close()
+
+		req, err := http.NewRequest(http.MethodGet, "https://localhost:1234", nil)
+		require.NoError(t, err)
+		req.Header.Set("Date", "Tue, 20 Apr 2021 02:07:55 GMT")
+		_, err = reqSigner.SignRequest(req)
+
+		// Not an invalid request, so the proxy responds with an internal error
+		var providerErr *KeyProviderError
+		require.ErrorAs(t, err, &providerErr)
+		var invalidReqErr *proxy.InvalidRequestError
+		require.False(t, errors.As(err, &invalidReqErr))
+	})
+}
+
+func TestNewRequestSignerWatchKeyFileProvider(t *testing.T) {
+	server := newFakeSigningService(t, map[string]string{"rsa": "rsa_test.pem"})
+	_, err := NewRequestSigner("test", config.SignerConfig{
+		KeyId:             "rsa",
+		KeyProvider:       remoteProvider,
+		Remote:            config.RemoteConfig{URL: server.URL},
+		WatchKeyFile:      true,
+		BodyDigestAlgo:    "SHA-256",
+		SignatureHashAlgo: "SHA-256",
+	}, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,380 @@
+package signer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	msgsigner "github.com/form3tech-oss/go-http-message-signatures"
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+)
+
+// RFC 9421 HTTP Message Signatures and RFC 9530 Digest Fields.
+const (
+	signatureInputHeaderKey = "Signature-Input"
+	signatureHeaderKey      = "Signature"
+	contentDigestHeaderKey  = "Content-Digest"
+
+	contentDigestComponent   = "content-digest"
+	signatureParamsComponent = "@signature-params"
+
+	defaultSignatureLabel = "sig1"
+	nonceSize             = 16
+)
+
+// derivedComponents are the RFC 9421 derived components that can be computed from a request.
+var derivedComponents = map[string]func(req *http.Request) string{
+	"@method": func(req *http.Request) string {
+		return req.Method
+	},
+	"@target-uri": func(req *http.Request) string {
+		target := url.URL{
+			Scheme:   strings.ToLower(req.URL.Scheme),
+			Host:     getAuthority(req),
+			Path:     req.URL.Path,
+			RawPath:  req.URL.RawPath,
+			RawQuery: req.URL.RawQuery,
+		}
+		return target.String()
+	},
+	"@authority": getAuthority,
+	"@scheme": func(req *http.Request) string {
+		return strings.ToLower(req.URL.Scheme)
+	},
+	"@request-target": func(req *http.Request) string {
+		return req.URL.RequestURI()
+	},
+	"@path": func(req *http.Request) string {
+		if path := req.URL.EscapedPath(); path != "" {
+			return path
+		}
+		return "/"
+	},
+	"@query": func(req *http.Request) string {
+		return "?" + req.URL.RawQuery
+	},
+}
+
+func getAuthority(req *http.Request) string {
+	if req.Host != "" {
+		return strings.ToLower(req.Host)
+	}
+	return strings.ToLower(req.URL.Host)
+}
+
+func isDerivedComponent(component string) bool {
+	return strings.HasPrefix(component, "@")
+}
+
+// rfc9421Algorithm signs a signature base with one of the algorithms registered by RFC 9421.
+type rfc9421Algorithm struct {
+	key  crypto.Signer
+	opts crypto.SignerOpts
+	name string
+	// ecdsaSize is the byte size of r and s for ECDSA keys, whose signatures are encoded as r || s.
+	ecdsaSize int
+}
+
+func newRFC9421Algorithm(key crypto.Signer, hash crypto.Hash) (*rfc9421Algorithm, error) {
+	switch pub := key.Public().(type) {
+	case *rsa.PublicKey:
+		switch hash {
+		case crypto.SHA256:
+			return &rfc9421Algorithm{key: key, opts: hash, name: "rsa-v1_5-sha256"}, nil
+		case crypto.SHA512:
+			return &rfc9421Algorithm{
+				key:  key,
+				opts: &rsa.PSSOptions{SaltLength: crypto.SHA512.Size(), Hash: hash},
+				name: "rsa-pss-sha512",
+			}, nil
+		default:
+			return nil, fmt.Errorf("signature hash algo '%s' cannot be used with RSA key in RFC 9421 profile, expected '%s' or '%s'", hash, crypto.SHA256, crypto.SHA512)
+		}
+	case *ecdsa.PublicKey:
+		curveHash, err := getCurveHash(pub.Curve)
+		if err != nil {
+			return nil, err
+		}
+		if hash != curveHash || hash == crypto.SHA512 {
+			return nil, fmt.Errorf("signature hash algo '%s' cannot be used with ECDSA %s key in RFC 9421 profile, only P-256 with '%s' and P-384 with '%s' are supported", hash, pub.Curve.Params().Name, crypto.SHA256, crypto.SHA384)
+		}
+		bitSize := pub.Curve.Params().BitSize
+		return &rfc9421Algorithm{
+			key:       key,
+			opts:      hash,
+			name:      fmt.Sprintf("ecdsa-p%d-%s", bitSize, getHashName(hash)),
+			ecdsaSize: (bitSize + 7) / 8,
+		}, nil
+	case ed25519.PublicKey:
+		if hash != crypto.SHA512 {
+			return nil, fmt.Errorf("signature hash algo '%s' cannot be used with Ed25519 key, expected '%s'", hash, crypto.SHA512)
+		}
+		return &rfc9421Algorithm{key: key, opts: crypto.Hash(0), name: ed25519Name}, nil
+	default:
+		return nil, fmt.Errorf("unsupported signer key type %T", pub)
+	}
+}
+
+func (a *rfc9421Algorithm) sign(rand io.Reader, base []byte) ([]byte, error) {
+	digest := base
+	if hash := a.opts.HashFunc(); hash != 0 {
+		hasher := hash.New()
+		if _, err := hasher.Write(base); err != nil {
+			return nil, fmt.Errorf("failed to hash signature base: %w", err)
+		}
+		digest = hasher.Sum(nil)
+	}
+
+	signature, err := a.key.Sign(rand, digest, a.opts)
+	if err != nil || a.ecdsaSize == 0 {
+		return signature, err
+	}
+
+	// crypto.Signer returns ASN.1 encoded ECDSA signatures, RFC 9421 requires the raw r || s form
+	var rs struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(signature, &rs); err != nil {
+		return nil, fmt.Errorf("failed to decode ECDSA signature: %w", err)
+	}
+	raw := make([]byte, 2*a.ecdsaSize)
+	rs.R.FillBytes(raw[:a.ecdsaSize])
+	rs.S.FillBytes(raw[a.ecdsaSize:])
+	return raw, nil
+}
+
+// rfc9421MessageSigner signs requests following RFC 9421, adding Signature-Input and Signature headers.
+type rfc9421MessageSigner struct {
+	algo       *rfc9421Algorithm
+	digestHash crypto.Hash
+	keyId      string
+	cfg        config.RFC9421Config
+	now        func() time.Time
+}
+
+func newRFC9421MessageSigner(key crypto.Signer, signatureHash crypto.Hash, digestHash crypto.Hash, keyId string, cfg config.RFC9421Config) (*rfc9421MessageSigner, error) {
+	algo, err := newRFC9421Algorithm(key, signatureHash)
+	if err != nil {
+		return nil, err
+	}
+	if digestHash != crypto.SHA256 && digestHash != crypto.SHA512 {
+		return nil, fmt.Errorf("body digest algo '%s' is not supported by Content-Digest, expected '%s' or '%s'", digestHash, crypto.SHA256, crypto.SHA512)
+	}
+	for _, component := range cfg.Components {
+		component = strings.ToLower(component)
+		if _, ok := derivedComponents[component]; isDerivedComponent(component) && !ok {
+			return nil, fmt.Errorf("unsupported derived component '%s'", component)
+		}
+		if _, err := sfString(component); err != nil {
+			return nil, fmt.Errorf("invalid component: %w", err)
+		}
+	}
+	// The string parameters are serialised with each signature, they are checked once here
+	if _, err := sfString(keyId); err != nil {
+		return nil, fmt.Errorf("invalid key id: %w", err)
+	}
+	if _, err := sfString(cfg.Tag); err != nil {
+		return nil, fmt.Errorf("invalid tag: %w", err)
+	}
+	if cfg.Label == "" {
+		cfg.Label = defaultSignatureLabel
+	}
+	return &rfc9421MessageSigner{
+		algo:       algo,
+		digestHash: digestHash,
+		keyId:      keyId,
+		cfg:        cfg,
+		now:        time.Now,
+	}, nil
+}
+
+// SignRequest signs the given components of the request. A Content-Digest header is computed first
+// if the content-digest component is requested.
+func (ms *rfc9421MessageSigner) SignRequest(req *http.Request, components []string) (*http.Request, error) {
+	if len(components) == 0 {
+		return nil, msgsigner.NewValidationError("no signature components set, at least one should be specified")
+	}
+
+	if contains(components, contentDigestComponent) {
+		digest, err := ms.generateContentDigest(req)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(contentDigestHeaderKey, digest)
+	}
+
+	signatureParams, err := ms.generateSignatureParams(components)
+	if err != nil {
+		return nil, msgsigner.NewSigningError("failed to generate signature parameters", err)
+	}
+
+	base, err := generateSignatureBase(req, components, signatureParams)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := ms.algo.sign(rand.Reader, base)
+	if err != nil {
+		return nil, msgsigner.NewSigningError("failed to sign request", err)
+	}
+
+	req.Header.Set(signatureInputHeaderKey, ms.cfg.Label+"="+signatureParams)
+	req.Header.Set(signatureHeaderKey, ms.cfg.Label+"=:"+base64.StdEncoding.EncodeToString(signature)+":")
+
+	return req, nil
+}
+
+// generateContentDigest returns the Content-Digest header value of the request body as defined by RFC 9530.
+func (ms *rfc9421MessageSigner) generateContentDigest(req *http.Request) (string, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		if err != nil {
+			return "", msgsigner.NewDataError("failed to read request body", err)
+		}
+		_ = req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	hasher := ms.digestHash.New()
+	hasher.Write(body)
+	// Content-Digest algorithm keys are the lowercase hash names, e.g. sha-256
+	return strings.ToLower(ms.digestHash.String()) + "=:" + base64.StdEncoding.EncodeToString(hasher.Sum(nil)) + ":", nil
+}
+
+// sfString serialises s as a structured field string as defined in section 4.1.6 of RFC 8941: only printable ASCII
+// characters are allowed, and only backslashes and double quotes are escaped.
+func sfString(s string) (string, error) {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c > 0x7e {
+			return "", fmt.Errorf("'%s' is not a valid structured field string, only printable ASCII characters are allowed", s)
+		}
+		if c == '\\' || c == '"' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	b.WriteByte('"')
+	return b.String(), nil
+}
+
+// generateSignatureParams returns the serialised inner list of components and signature parameters,
+// used both as the @signature-params component and as the Signature-Input member value.
+func (ms *rfc9421MessageSigner) generateSignatureParams(components []string) (string, error) {
+	var b strings.Builder
+	b.WriteString("(")
+	for i, component := range components {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		name, err := sfString(component)
+		if err != nil {
+			return "", fmt.Errorf("invalid component name: %w", err)
+		}
+		b.WriteString(name)
+	}
+	b.WriteString(")")
+
+	created := ms.now()
+	if ms.cfg.Created {
+		b.WriteString(";created=")
+		b.WriteString(strconv.FormatInt(created.Unix(), 10))
+	}
+	if ms.cfg.Expires > 0 {
+		b.WriteString(";expires=")
+		b.WriteString(strconv.FormatInt(created.Add(ms.cfg.Expires).Unix(), 10))
+	}
+	if err := writeStringParam(&b, "keyid", ms.keyId); err != nil {
+		return "", err
+	}
+	if ms.cfg.IncludeAlg {
+		if err := writeStringParam(&b, "alg", ms.algo.name); err != nil {
+			return "", err
+		}
+	}
+	if ms.cfg.Nonce {
+		nonce := make([]byte, nonceSize)
+		if _, err := rand.Read(nonce); err != nil {
+			return "", fmt.Errorf("failed to generate nonce: %w", err)
+		}
+		if err := writeStringParam(&b, "nonce", base64.RawURLEncoding.EncodeToString(nonce)); err != nil {
+			return "", err
+		}
+	}
+	if ms.cfg.Tag != "" {
+		if err := writeStringParam(&b, "tag", ms.cfg.Tag); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+// writeStringParam writes a signature parameter with a string value.
+func writeStringParam(b *strings.Builder, name string, value string) error {
+	serialised, err := sfString(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s parameter: %w", name, err)
+	}
+	b.WriteString(";" + name + "=")
+	b.WriteString(serialised)
+	return nil
+}
+
+// generateSignatureBase creates the signature base as defined in section 2.5 of RFC 9421.
+func generateSignatureBase(req *http.Request, components []string, signatureParams string) ([]byte, error) {
+	var b bytes.Buffer
+	for _, component := range components {
+		var value string
+		if derive, ok := derivedComponents[component]; ok {
+			value = derive(req)
+		} else {
+			headerValues := req.Header.Values(component)
+			if len(headerValues) == 0 {
+				return nil, msgsigner.NewDataError(fmt.Sprintf("required signature component not present: %s", component), nil)
+			}
+			values := make([]string, len(headerValues))
+			for i, headerValue := range headerValues {
+				values[i] = strings.TrimSpace(headerValue)
+			}
+			value = strings.Join(values, ", ")
+		}
+		name, err := sfString(component)
+		if err != nil {
+			return nil, msgsigner.NewDataError("invalid signature component name", err)
+		}
+		b.WriteString(name)
+		b.WriteString(": ")
+		b.WriteString(value)
+		b.WriteString("\n")
+	}
+	b.WriteString(`"` + signatureParamsComponent + `"`)
+	b.WriteString(": ")
+	b.WriteString(signatureParams)
+	return b.Bytes(), nil
+}
+
+func contains(s []string, o string) bool {
+	for _, item := range s {
+		if item == o {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,359 @@
+package signer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	"github.com/stretchr/testify/require"
+)
+
+// Request from RFC 9421 appendix B.2
+func rfc9421TestRequest(t *testing.T) *http.Request {
+	body := `{"hello": "world"}`
+	req, err := http.NewRequest(http.MethodPost, "http://example.com/foo?param=Value&Pet=dog", strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Date", "Tue, 20 Apr 2021 02:07:55 GMT")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Length", "18")
+	return req
+}
+
+func newTestRFC9421MessageSigner(t *testing.T, keyFile string, hash crypto.Hash, cfg config.RFC9421Config) *rfc9421MessageSigner {
+	key, err := loadKey(keyFile, nil)
+	require.NoError(t, err)
+	ms, err := newRFC9421MessageSigner(key, hash, crypto.SHA512, "test-key-ed25519", cfg)
+	require.NoError(t, err)
+	ms.now = func() time.Time {
+		return time.Unix(1618884473, 0)
+	}
+	return ms
+}
+
+func TestRFC9421SignRequest(t *testing.T) {
+	// Signature from RFC 9421 appendix B.2.6, Ed25519 signatures are deterministic
+	ms := newTestRFC9421MessageSigner(t, "ed25519_rfc9421_test.pem", crypto.SHA512, config.RFC9421Config{
+		Label:   "sig-b26",
+		Created: true,
+	})
+
+	req, err := ms.SignRequest(rfc9421TestRequest(t), []string{"date", "@method", "@path", "@authority", "content-type", "content-length"})
+	require.NoError(t, err)
+
+	require.Equal(t,
+		`sig-b26=("date" "@method" "@path" "@authority" "content-type" "content-length");created=1618884473;keyid="test-key-ed25519"`,
+		req.Header.Get(signatureInputHeaderKey),
+	)
+	require.Equal(t,
+		"sig-b26=:wqcAqbmYJ2ji2glfAMaRy4gruYYnx2nEFN2HN6jrnDnQCK1u02Gb04v9EDgwUPiu4A0w6vuQv5lIp5WPpBKRCw==:",
+		req.Header.Get(signatureHeaderKey),
+	)
+}
+
+func TestRFC9421ContentDigest(t *testing.T) {
+	ms := newTestRFC9421MessageSigner(t, "ed25519_rfc9421_test.pem", crypto.SHA512, config.RFC9421Config{})
+
+	req, err := ms.SignRequest(rfc9421TestRequest(t), []string{"@method", contentDigestComponent})
+	require.NoError(t, err)
+
+	// Content-Digest from RFC 9421 appendix B.2
+	require.Equal(t,
+		"sha-512=:WZDPaVn/7XgHaAy8pmojAkGWoRx2UFChF41A2svX+TaPm+AbwAgBWnrIiYllu7BNNyealdVLvRwEmTHWXvJwew==:",
+		req.Header.Get(contentDigestHeaderKey),
+	)
+	require.Equal(t, `sig1=("@method" "content-digest");keyid="test-key-ed25519"`, req.Header.Get(signatureInputHeaderKey))
+
+	// The body must still be readable after computing the digest
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	require.Equal(t, `{"hello": "world"}`, string(body))
+}
+
+func TestRFC9421SignatureParams(t *testing.T) {
+	ms := newTestRFC9421MessageSigner(t, "ecdsa_p256_test.pem", crypto.SHA256, config.RFC9421Config{
+		Label:      "proxy",
+		Created:    true,
+		Expires:    5 * time.Minute,
+		Nonce:      true,
+		Tag:        "form3",
+		IncludeAlg: true,
+	})
+
+	req, err := ms.SignRequest(rfc9421TestRequest(t), []string{"@method"})
+	require.NoError(t, err)
+
+	require.Regexp(t,
+		regexp.MustCompile(`^proxy=\("@method"\);created=1618884473;expires=1618884773;keyid="test-key-ed25519";alg="ecdsa-p256-sha256";nonce="[A-Za-z0-9_-]{22}";tag="form3"$`),
+		req.Header.Get(signatureInputHeaderKey),
+	)
+}
+
+func TestSFString(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		expected    string
+		expectedErr bool
+	}{
+		{"plain", "test-key", `"test-key"`, false},
+		{"empty", "", `""`, false},
+		{"escaped characters", `a"b\c`, `"a\"b\\c"`, false},
+		{"printable characters kept as is", "a b/c'd~", `"a b/c'd~"`, false},
+		{"control character", "a\tb", "", true},
+		{"delete character", "a\x7fb", "", true},
+		{"non-ASCII character", "clé", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serialised, err := sfString(tt.value)
+			if tt.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, serialised)
+		})
+	}
+}
+
+func TestNewRFC9421MessageSignerInvalidString(t *testing.T) {
+	key, err := loadKey("ed25519_rfc9421_test.pem", nil)
+	require.NoError(t, err)
+
+	_, err = newRFC9421MessageSigner(key, crypto.SHA512, crypto.SHA512, "clé", config.RFC9421Config{})
+	require.EqualError(t, err, "invalid key id: 'clé' is not a valid structured field string, only printable ASCII characters are allowed")
+	_, err = newRFC9421MessageSigner(key, crypto.SHA512, crypto.SHA512, "test-key", config.RFC9421Config{Tag: "form3\n"})
+	require.Error(t, err)
+	_, err = newRFC9421MessageSigner(key, crypto.SHA512, crypto.SHA512, "test-key", config.RFC9421Config{Components: []string{"x-clé"}})
+	require.Error(t, err)
+
+	// Backslashes and double quotes are escaped
+	ms := newTestRFC9421MessageSigner(t, "ed25519_rfc9421_test.pem", crypto.SHA512, config.RFC9421Config{Tag: `form3 "proxy" \ v1`})
+	req, err := ms.SignRequest(rfc9421TestRequest(t), []string{"@method"})
+	require.NoError(t, err)
+	require.Equal(t, `sig1=("@method");keyid="test-key-ed25519";tag="form3 \"proxy\" \\ v1"`, req.Header.Get(signatureInputHeaderKey))
+}
+
+func TestRFC9421ECDSASignature(t *testing.T) {
+	ms := newTestRFC9421MessageSigner(t, "ecdsa_p256_test.pem", crypto.SHA256, config.RFC9421Config{})
+	components := []string{"@method", "@target-uri", "@authority", "@scheme", "@request-target", "@path", "@query", "date"}
+
+	req, err := ms.SignRequest(rfc9421TestRequest(t), components)
+	require.NoError(t, err)
+
+	signatureParams := strings.TrimPrefix(req.Header.Get(signatureInputHeaderKey), "sig1=")
+	base, err := generateSignatureBase(req, components, signatureParams)
+	require.NoError(t, err)
+	require.Equal(t, `"@method": POST
+"@target-uri": http://example.com/foo?param=Value&Pet=dog
+"@authority": example.com
+"@scheme": http
+"@request-target": /foo?param=Value&Pet=dog
+"@path": /foo
+"@query": ?param=Value&Pet=dog
+"date": Tue, 20 Apr 2021 02:07:55 GMT
+"@signature-params": ("@method" "@target-uri" "@authority" "@scheme" "@request-target" "@path" "@query" "date");keyid="test-key-ed25519"`, string(base))
+
+	// ECDSA signatures are encoded as r || s
+	signature, err := base64.StdEncoding.DecodeString(strings.Trim(strings.TrimPrefix(req.Header.Get(signatureHeaderKey), "sig1="), ":"))
+	require.NoError(t, err)
+	require.Len(t, signature, 64)
+
+	key, err := loadKey("ecdsa_p256_test.pem", nil)
+	require.NoError(t, err)
+	digest := sha256.Sum256(base)
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	require.True(t, ecdsa.Verify(&key.(*ecdsa.PrivateKey).PublicKey, digest[:], r, s))
+}
+
+func TestRFC9421MissingHeaderComponent(t *testing.T) {
+	ms := newTestRFC9421MessageSigner(t, "ed25519_rfc9421_test.pem", crypto.SHA512, config.RFC9421Config{})
+
+	_, err := ms.SignRequest(rfc9421TestRequest(t), []string{"@method", "x-missing"})
+	var dataErr *DataError
+	require.ErrorAs(t, err, &dataErr)
+}
+
+func TestNewRFC9421Algorithm(t *testing.T) {
+	tests := []struct {
+		name         string
+		keyFile      string
+		hash         crypto.Hash
+		expectedName string
+		errCheckFn   errCheckFn
+	}{
+		{
+			"rsa with sha-256",
+			"rsa_test.pem",
+			crypto.SHA256,
+			"rsa-v1_5-sha256",
+			require.NoError,
+		},
+		{
+			"rsa with sha-512",
+			"rsa_test.pem",
+			crypto.SHA512,
+			"rsa-pss-sha512",
+			require.NoError,
+		},
+		{
+			"rsa with sha-384",
+			"rsa_test.pem",
+			crypto.SHA384,
+			"",
+			require.Error,
+		},
+		{
+			"ecdsa p-256 with sha-256",
+			"ecdsa_p256_test.pem",
+			crypto.SHA256,
+			"ecdsa-p256-sha256",
+			require.NoError,
+		},
+		{
+			"ecdsa p-384 with sha-384",
+			"ecdsa_p384_test.pem",
+			crypto.SHA384,
+			"ecdsa-p384-sha384",
+			require.NoError,
+		},
+		{
+			"ecdsa p-384 with sha-256",
+			"ecdsa_p384_test.pem",
+			crypto.SHA256,
+			"",
+			require.Error,
+		},
+		{
+			"ed25519",
+			"ed25519_test.pem",
+			crypto.SHA512,
+			"ed25519",
+			require.NoError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := loadKey(test.keyFile, nil)
+			require.NoError(t, err)
+
+			algo, err := newRFC9421Algorithm(key, test.hash)
+			test.errCheckFn(t, err)
+			if err == nil {
+				require.Equal(t, test.expectedName, algo.name)
+			}
+		})
+	}
+}
+
+func TestGetSignatureComponents(t *testing.T) {
+	dummyUrl := "https://localhost:1234"
+	dummyBody := "{\"name\":\"travis\"}"
+
+	tests := []struct {
+		name               string
+		components         []string
+		expectedComponents []string
+		errCheckFn         errCheckFn
+
+		// incoming request config
+		method  string
+		body    io.Reader
+		headers map[string]string
+	}{
+		{
+			"GET with derived components and partially matched headers",
+			[]string{"@method", "@target-uri", "Date", "content-type"},
+			[]string{"@method", "@target-uri", "date"},
+			require.NoError,
+			http.MethodGet,
+			nil,
+			map[string]string{
+				"date": "foo",
+			},
+		},
+		{
+			"GET skips content-digest",
+			[]string{"@method", contentDigestComponent},
+			[]string{"@method"},
+			require.NoError,
+			http.MethodGet,
+			nil,
+			nil,
+		},
+		{
+			"POST includes content-digest",
+			[]string{"@method", contentDigestComponent},
+			[]string{"@method", contentDigestComponent},
+			require.NoError,
+			http.MethodPost,
+			strings.NewReader(dummyBody),
+			nil,
+		},
+		{
+			"no matched components",
+			[]string{"date"},
+			nil,
+			require.Error,
+			http.MethodGet,
+			nil,
+			nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, dummyUrl, test.body)
+			require.NoError(t, err)
+			for k, v := range test.headers {
+				req.Header.Set(k, v)
+			}
+
+			reqSigner, err := NewRequestSigner("test", config.SignerConfig{
+				KeyId:             "dfb4c78a-e141-4144-aa68-8ec605484d63",
+				KeyFilePath:       "rsa_test.pem",
+				BodyDigestAlgo:    "SHA-256",
+				SignatureHashAlgo: "SHA-256",
+				Profile:           "RFC9421",
+				RFC9421: config.RFC9421Config{
+					Components: test.components,
+				},
+			}, nil)
+			require.NoError(t, err)
+
+			actualComponents, err := reqSigner.(*requestSigner).getSignatureComponents(req)
+			test.errCheckFn(t, err)
+
+			require.Equal(t, test.expectedComponents, actualComponents)
+		})
+	}
+}
+
+func TestNewRequestSignerProfile(t *testing.T) {
+	cfg := config.SignerConfig{
+		KeyId:             "dfb4c78a-e141-4144-aa68-8ec605484d63",
+		KeyFilePath:       "rsa_test.pem",
+		BodyDigestAlgo:    "SHA-256",
+		SignatureHashAlgo: "SHA-256",
+		Profile:           "unknown",
+	}
+	_, err := NewRequestSigner("test", cfg, nil)
+	require.Error(t, err)
+
+	cfg.Profile = "rfc9421"
+	cfg.RFC9421.Components = []string{"@unknown"}
+	_, err = NewRequestSigner("test", cfg, nil)
+	require.Error(t, err)
+}
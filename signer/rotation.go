@@ -0,0 +1,124 @@
+package signer
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// rotationMetricInterval is how often the key rotation metrics are updated.
+const rotationMetricInterval = time.Second
+
+// isKeyActive reports whether the key can be used at the given time. notBefore is inclusive and
+// notAfter exclusive, zero times are unbounded.
+func isKeyActive(key *signingKey, at time.Time) bool {
+	return (key.cfg.NotBefore.IsZero() || !at.Before(key.cfg.NotBefore)) &&
+		(key.cfg.NotAfter.IsZero() || at.Before(key.cfg.NotAfter))
+}
+
+// getActiveKey returns the key to sign with at the given time, nil if none is active. When the validity
+// of several keys overlaps, the key with the latest notBefore is used, so a new key takes over at its notBefore.
+func getActiveKey(keys []*signingKey, at time.Time) *signingKey {
+	var active *signingKey
+	for _, key := range keys {
+		if !isKeyActive(key, at) {
+			continue
+		}
+		if active == nil || key.cfg.NotBefore.After(active.cfg.NotBefore) {
+			active = key
+		}
+	}
+	return active
+}
+
+// getNextRotation returns the first time after the given time at which the active key changes,
+// false if no rotation is scheduled.
+func getNextRotation(keys []*signingKey, at time.Time) (time.Time, bool) {
+	active := getActiveKey(keys, at)
+
+	var (
+		next  time.Time
+		found bool
+	)
+	for _, key := range keys {
+		for _, boundary := range []time.Time{key.cfg.NotBefore, key.cfg.NotAfter} {
+			if !boundary.After(at) || (found && !boundary.Before(next)) {
+				continue
+			}
+			if getActiveKey(keys, boundary) != active {
+				next, found = boundary, true
+			}
+		}
+	}
+	return next, found
+}
+
+// rotationPublisher periodically publishes the active key and the time left until the next rotation,
+// and logs key rotations.
+type rotationPublisher struct {
+	rs         *requestSigner
+	lastActive *signingKey
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+func newRotationPublisher(rs *requestSigner) *rotationPublisher {
+	p := &rotationPublisher{
+		rs:   rs,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	p.publish()
+	go p.run()
+	return p
+}
+
+func (p *rotationPublisher) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(rotationMetricInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.publish()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+func (p *rotationPublisher) publish() {
+	now := p.rs.now()
+	active := getActiveKey(p.rs.keys, now)
+
+	secondsUntilRotation := float64(-1)
+	if next, ok := getNextRotation(p.rs.keys, now); ok {
+		secondsUntilRotation = next.Sub(now).Seconds()
+	}
+
+	for _, key := range p.rs.keys {
+		if key == active {
+			p.rs.metricPublisher.SetActiveKey(p.rs.route, key.cfg.KeyId, true)
+			p.rs.metricPublisher.SetSecondsUntilKeyRotation(p.rs.route, key.cfg.KeyId, secondsUntilRotation)
+		} else {
+			p.rs.metricPublisher.SetActiveKey(p.rs.route, key.cfg.KeyId, false)
+			p.rs.metricPublisher.SetSecondsUntilKeyRotation(p.rs.route, key.cfg.KeyId, 0)
+		}
+	}
+
+	if active != p.lastActive {
+		logger := log.WithField("route", p.rs.route)
+		if active == nil {
+			logger.Error("no signer key is active, requests cannot be signed")
+		} else {
+			logger.WithField("key_id", active.cfg.KeyId).Info("signer key is now active")
+		}
+		p.lastActive = active
+	}
+}
+
+func (p *rotationPublisher) close() {
+	close(p.stop)
+	<-p.done
+}
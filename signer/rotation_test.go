@@ -0,0 +1,193 @@
+package signer
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	"github.com/golang/mock/gomock"
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	rotationTime = time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	overlapTime  = time.Date(2024, 1, 30, 0, 0, 0, 0, time.UTC)
+)
+
+// testRotationKeys returns key-a valid until the rotation time, and key-b valid from the day before.
+func testRotationKeys() []*signingKey {
+	return []*signingKey{
+		{cfg: config.KeyConfig{KeyId: "key-a", NotAfter: rotationTime}},
+		{cfg: config.KeyConfig{KeyId: "key-b", NotBefore: overlapTime}},
+	}
+}
+
+func TestGetActiveKey(t *testing.T) {
+	tests := []struct {
+		name          string
+		keys          []*signingKey
+		at            time.Time
+		expectedKeyId string
+	}{
+		{
+			"before overlap",
+			testRotationKeys(),
+			overlapTime.Add(-time.Second),
+			"key-a",
+		},
+		{
+			"newest key wins during overlap",
+			testRotationKeys(),
+			overlapTime,
+			"key-b",
+		},
+		{
+			"after rotation",
+			testRotationKeys(),
+			rotationTime,
+			"key-b",
+		},
+		{
+			"single unbounded key",
+			[]*signingKey{{cfg: config.KeyConfig{KeyId: "key-a"}}},
+			rotationTime,
+			"key-a",
+		},
+		{
+			"no active key",
+			[]*signingKey{{cfg: config.KeyConfig{KeyId: "key-a", NotAfter: rotationTime}}},
+			rotationTime,
+			"",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key := getActiveKey(test.keys, test.at)
+			if test.expectedKeyId == "" {
+				require.Nil(t, key)
+			} else {
+				require.Equal(t, test.expectedKeyId, key.cfg.KeyId)
+			}
+		})
+	}
+}
+
+func TestGetNextRotation(t *testing.T) {
+	keys := []*signingKey{
+		{cfg: config.KeyConfig{KeyId: "key-a", NotAfter: rotationTime}},
+		{cfg: config.KeyConfig{KeyId: "key-b", NotBefore: rotationTime, NotAfter: rotationTime.Add(24 * time.Hour)}},
+	}
+
+	next, ok := getNextRotation(keys, overlapTime)
+	require.True(t, ok)
+	require.Equal(t, rotationTime, next)
+
+	next, ok = getNextRotation(keys, rotationTime)
+	require.True(t, ok)
+	require.Equal(t, rotationTime.Add(24*time.Hour), next)
+
+	_, ok = getNextRotation(keys, rotationTime.Add(24*time.Hour))
+	require.False(t, ok)
+}
+
+func TestRotationPublisher(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockMetricPublisher := NewMockMetricPublisher(mockCtrl)
+	rs := &requestSigner{
+		route:           "test",
+		keys:            testRotationKeys(),
+		metricPublisher: mockMetricPublisher,
+		now: func() time.Time {
+			return overlapTime.Add(-time.Minute)
+		},
+	}
+
+	mockMetricPublisher.EXPECT().SetActiveKey("test", "key-a", true)
+	mockMetricPublisher.EXPECT().SetSecondsUntilKeyRotation("test", "key-a", float64(60))
+	mockMetricPublisher.EXPECT().SetActiveKey("test", "key-b", false)
+	mockMetricPublisher.EXPECT().SetSecondsUntilKeyRotation("test", "key-b", float64(0))
+
+	hook := logtest.NewGlobal()
+	defer log.StandardLogger().ReplaceHooks(log.LevelHooks{})
+
+	p := &rotationPublisher{rs: rs}
+	p.publish()
+	require.Equal(t, rs.keys[0], p.lastActive)
+
+	// The activation is logged with the route, as each route rotates its own keys
+	entry := hook.LastEntry()
+	require.NotNil(t, entry)
+	require.Equal(t, "signer key is now active", entry.Message)
+	require.Equal(t, log.Fields{"route": "test", "key_id": "key-a"}, entry.Data)
+}
+
+func TestSignRequestKeyRotation(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockMetricPublisher := NewMockMetricPublisher(mockCtrl)
+	mockMetricPublisher.EXPECT().SetActiveKey(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+	mockMetricPublisher.EXPECT().SetSecondsUntilKeyRotation(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+
+	reqSigner, err := NewRequestSigner("test", config.SignerConfig{
+		BodyDigestAlgo:    "SHA-256",
+		SignatureHashAlgo: "SHA-256",
+		Keys: []config.KeyConfig{
+			{KeyId: "key-a", KeyFilePath: "rsa_test.pem", NotAfter: rotationTime},
+			{KeyId: "key-b", KeyFilePath: "rsa_pkcs8_test.pem", NotBefore: rotationTime},
+		},
+		Headers: config.HeadersConfig{
+			SignatureHeaders: []string{"date"},
+		},
+	}, mockMetricPublisher)
+	require.NoError(t, err)
+	// Stop publishing the rotation metrics, as the clock is replaced below. Requests can still be signed.
+	require.NoError(t, reqSigner.(*requestSigner).Close())
+
+	tests := []struct {
+		name          string
+		now           time.Time
+		expectedKeyId string
+	}{
+		{
+			"before rotation",
+			rotationTime.Add(-time.Second),
+			"key-a",
+		},
+		{
+			"after rotation",
+			rotationTime,
+			"key-b",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reqSigner.(*requestSigner).now = func() time.Time {
+				return test.now
+			}
+
+			req, err := http.NewRequest(http.MethodGet, "https://localhost:1234", nil)
+			require.NoError(t, err)
+			req.Header.Set("Date", test.now.Format(http.TimeFormat))
+
+			signedReq, err := reqSigner.SignRequest(req)
+			require.NoError(t, err)
+			require.True(t, strings.Contains(signedReq.Header.Get("Authorization"), `keyId="`+test.expectedKeyId+`"`))
+		})
+	}
+}
+
+func TestNewRequestSignerInvalidKeyValidity(t *testing.T) {
+	_, err := NewRequestSigner("test", config.SignerConfig{
+		BodyDigestAlgo:    "SHA-256",
+		SignatureHashAlgo: "SHA-256",
+		Keys: []config.KeyConfig{
+			{KeyId: "key-a", KeyFilePath: "rsa_test.pem", NotBefore: rotationTime, NotAfter: overlapTime},
+		},
+	}, nil)
+	require.Error(t, err)
+}
@@ -2,37 +2,90 @@ package signer
 
 import (
 	"crypto"
-	"crypto/rsa"
-	"crypto/x509"
-	"encoding/pem"
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	msgsigner "github.com/form3tech-oss/go-http-message-signatures"
 	"github.com/form3tech-oss/http-message-signing-proxy/config"
 	"github.com/form3tech-oss/http-message-signing-proxy/proxy"
+	"github.com/form3tech-oss/http-message-signing-proxy/watcher"
+	log "github.com/sirupsen/logrus"
 )
 
 const (
 	requestTargetHeaderKey = "(request-target)"
 	digestHeaderKey        = "digest"
+
+	cavageProfile  = "cavage"
+	rfc9421Profile = "rfc9421"
+
+	authorizationPlacement = "authorization"
+	signaturePlacement     = "signature"
 )
 
 type DataError = msgsigner.DataError
 
+// messageSigner signs the given headers of a request, or its components in the RFC 9421 profile.
+type messageSigner interface {
+	SignRequest(req *http.Request, headers []string) (*http.Request, error)
+}
+
 type requestSigner struct {
-	*msgsigner.MessageSigner
-	headerConfig config.HeadersConfig
+	// route is the name of the route the signer belongs to, used in logs and metrics
+	route             string
+	cfg               config.SignerConfig
+	profile           string
+	components        []string
+	signatureHashAlgo crypto.Hash
+	digestHashAlgo    crypto.Hash
+	metricPublisher   proxy.MetricPublisher
+	keyProvider       KeyProvider
+	keys              []*signingKey
+	now               func() time.Time
+	// rotation publishes the key rotation metrics, only when several keys are configured
+	rotation *rotationPublisher
+}
+
+// signingKey is one of the keys of the signer, its key material is replaced when the key file changes.
+type signingKey struct {
+	cfg config.KeyConfig
+	// state holds the current *signingState
+	state   atomic.Value
+	watcher *watcher.FileWatcher
 }
 
-func NewRequestSigner(cfg config.SignerConfig) (proxy.RequestSigner, error) {
-	key, err := loadKey(cfg.KeyFilePath)
+// signingState is the part of the signer that depends on the key material.
+type signingState struct {
+	messageSigner messageSigner
+	key           crypto.Signer
+}
+
+// NewRequestSigner returns a request signer for the given route, whose name labels the key metrics.
+func NewRequestSigner(route string, cfg config.SignerConfig, metricPublisher proxy.MetricPublisher) (proxy.RequestSigner, error) {
+	keyProvider, err := newKeyProvider(cfg)
 	if err != nil {
 		return nil, err
 	}
 
+	reqSigner, err := NewRequestSignerWithKeyProvider(route, cfg, keyProvider, metricPublisher)
+	if err != nil {
+		_ = keyProvider.Close()
+		return nil, err
+	}
+	return reqSigner, nil
+}
+
+// NewRequestSignerWithKeyProvider returns a request signer whose keys are taken from keyProvider rather than
+// the provider set in the config. The key provider is closed when the signer is closed.
+func NewRequestSignerWithKeyProvider(route string, cfg config.SignerConfig, keyProvider KeyProvider, metricPublisher proxy.MetricPublisher) (proxy.RequestSigner, error) {
+	if _, ok := keyProvider.(*fileKeyProvider); cfg.WatchKeyFile && !ok {
+		return nil, fmt.Errorf("watchKeyFile is only supported by the '%s' key provider", fileProvider)
+	}
+
 	signatureHashAlgo, err := getHashAlgo(cfg.SignatureHashAlgo)
 	if err != nil {
 		return nil, err
@@ -43,29 +96,186 @@ func NewRequestSigner(cfg config.SignerConfig) (proxy.RequestSigner, error) {
 		return nil, err
 	}
 
-	signer, err := msgsigner.NewRSASigner(key, signatureHashAlgo)
+	profile := strings.ToLower(cfg.Profile)
+	switch profile {
+	case cavageProfile, "":
+		profile = cavageProfile
+	case rfc9421Profile:
+	default:
+		return nil, fmt.Errorf("unknown signer profile '%s'", cfg.Profile)
+	}
+
+	components := make([]string, len(cfg.RFC9421.Components))
+	for i, component := range cfg.RFC9421.Components {
+		components[i] = strings.ToLower(component)
+	}
+
+	rs := &requestSigner{
+		route:             route,
+		cfg:               cfg,
+		profile:           profile,
+		components:        components,
+		signatureHashAlgo: signatureHashAlgo,
+		digestHashAlgo:    digestHashAlgo,
+		metricPublisher:   metricPublisher,
+		keyProvider:       keyProvider,
+		now:               time.Now,
+	}
+
+	for _, keyCfg := range cfg.GetKeys() {
+		if err := rs.addKey(keyCfg); err != nil {
+			rs.closeKeys()
+			return nil, err
+		}
+	}
+
+	if len(cfg.Keys) > 0 {
+		rs.rotation = newRotationPublisher(rs)
+	}
+
+	return rs, nil
+}
+
+func (rs *requestSigner) addKey(keyCfg config.KeyConfig) error {
+	if !keyCfg.NotBefore.IsZero() && !keyCfg.NotAfter.IsZero() && !keyCfg.NotAfter.After(keyCfg.NotBefore) {
+		return fmt.Errorf("notAfter of key '%s' must be after its notBefore", keyCfg.KeyId)
+	}
+
+	state, err := rs.loadSigningState(keyCfg)
+	if err != nil {
+		return fmt.Errorf("failed to load key '%s': %w", keyCfg.KeyId, err)
+	}
+	key := &signingKey{cfg: keyCfg}
+	key.state.Store(state)
+	rs.keys = append(rs.keys, key)
+
+	if rs.cfg.WatchKeyFile {
+		key.watcher, err = watcher.NewFileWatcher(keyCfg.KeyFilePath, func() {
+			rs.reloadKey(key)
+		})
+		if err != nil {
+			return NewKeyFileError(keyCfg.KeyFilePath, err)
+		}
+	}
+	return nil
+}
+
+// loadSigningState gets the key from the key provider and checks that it can be used with the configured algorithms.
+func (rs *requestSigner) loadSigningState(keyCfg config.KeyConfig) (*signingState, error) {
+	key, err := rs.keyProvider.GetSigner(keyCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var msgSigner messageSigner
+	if rs.profile == rfc9421Profile {
+		msgSigner, err = newRFC9421MessageSigner(key, rs.signatureHashAlgo, rs.digestHashAlgo, keyCfg.KeyId, rs.cfg.RFC9421)
+	} else {
+		msgSigner, err = newCavageMessageSigner(key, rs.signatureHashAlgo, rs.digestHashAlgo, keyCfg.KeyId, rs.cfg.HeaderPlacement)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	msgSigner, err := msgsigner.NewMessageSigner(digestHashAlgo, signer, cfg.KeyId, msgsigner.Authorization)
+	return &signingState{
+		messageSigner: msgSigner,
+		key:           key,
+	}, nil
+}
+
+func (k *signingKey) getSigningState() *signingState {
+	return k.state.Load().(*signingState)
+}
+
+// reloadKey replaces the key material if the key file holds a new valid key. Requests being signed keep using
+// the previous key. If the new key cannot be loaded, the previous key is kept.
+func (rs *requestSigner) reloadKey(key *signingKey) {
+	logger := log.WithFields(log.Fields{
+		"route":         rs.route,
+		"key_id":        key.cfg.KeyId,
+		"key_file_path": key.cfg.KeyFilePath,
+	})
+
+	state, err := rs.loadSigningState(key.cfg)
+	if err != nil {
+		logger.WithError(err).Error("failed to reload signer key, keeping the current key")
+		rs.metricPublisher.IncrementKeyReloadErrorCount(rs.route, key.cfg.KeyId)
+		return
+	}
+
+	if isSameKey(key.getSigningState().key, state.key) {
+		return
+	}
+	key.state.Store(state)
+	logger.Info("signer key reloaded")
+}
+
+// Close stops watching the key files and publishing the key rotation metrics, then closes the key provider.
+func (rs *requestSigner) Close() error {
+	if rs.rotation != nil {
+		rs.rotation.close()
+	}
+
+	err := rs.closeKeys()
+	if providerErr := rs.keyProvider.Close(); err == nil {
+		err = providerErr
+	}
+	return err
+}
+
+func (rs *requestSigner) closeKeys() error {
+	var firstErr error
+	for _, key := range rs.keys {
+		if key.watcher == nil {
+			continue
+		}
+		if err := key.watcher.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// newCavageMessageSigner returns a signer for the draft-cavage-http-signatures scheme.
+func newCavageMessageSigner(key crypto.Signer, signatureHashAlgo crypto.Hash, digestHashAlgo crypto.Hash, keyId string, headerPlacement string) (messageSigner, error) {
+	targetHeader, err := getTargetHeader(headerPlacement)
 	if err != nil {
 		return nil, err
 	}
 
-	return &requestSigner{
-		MessageSigner: msgSigner,
-		headerConfig:  cfg.Headers,
-	}, err
+	signer, err := newAlgorithmSigner(key, signatureHashAlgo)
+	if err != nil {
+		return nil, err
+	}
+	return msgsigner.NewMessageSigner(digestHashAlgo, signer, keyId, targetHeader)
 }
 
 func (rs *requestSigner) SignRequest(req *http.Request) (*http.Request, error) {
-	headers, err := rs.getSignatureHeaders(req)
+	var (
+		headers []string
+		err     error
+	)
+	if rs.profile == rfc9421Profile {
+		headers, err = rs.getSignatureComponents(req)
+	} else {
+		headers, err = rs.getSignatureHeaders(req)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	signedReq, err := rs.MessageSigner.SignRequest(req, headers)
+	now := rs.now()
+	key := getActiveKey(rs.keys, now)
+	if key == nil {
+		return nil, fmt.Errorf("no signer key is active at %s", now.UTC().Format(time.RFC3339))
+	}
+
+	signedReq, err := key.getSigningState().messageSigner.SignRequest(req, headers)
+	// Failures of the key provider are not caused by the request, even though they are wrapped in a SigningError
+	var providerErr *KeyProviderError
+	if errors.As(err, &providerErr) {
+		return nil, err
+	}
 	switch err.(type) {
 	case *msgsigner.DataError, *msgsigner.SigningError:
 		return nil, proxy.NewInvalidRequestError(err)
@@ -77,7 +287,7 @@ func (rs *requestSigner) SignRequest(req *http.Request) (*http.Request, error) {
 func (rs *requestSigner) getSignatureHeaders(req *http.Request) ([]string, error) {
 	// Get the intersection of request's headers and config's signature headers
 	var headers []string
-	for _, header := range rs.headerConfig.SignatureHeaders {
+	for _, header := range rs.cfg.Headers.SignatureHeaders {
 		if req.Header.Get(header) != "" {
 			headers = append(headers, header)
 		}
@@ -87,23 +297,48 @@ func (rs *requestSigner) getSignatureHeaders(req *http.Request) ([]string, error
 	}
 
 	// Include 'digest' header for PUT, POST and PATCH requests only
-	if rs.headerConfig.IncludeDigest && shouldHaveBody(req) {
+	if rs.cfg.Headers.IncludeDigest && shouldHaveBody(req) {
 		headers = append(headers, digestHeaderKey)
 	}
 
 	// Include '(request-target)' header
-	if rs.headerConfig.IncludeRequestTarget {
+	if rs.cfg.Headers.IncludeRequestTarget {
 		headers = append(headers, requestTargetHeaderKey)
 	}
 
 	return headers, nil
 }
 
+func (rs *requestSigner) getSignatureComponents(req *http.Request) ([]string, error) {
+	// Derived components are always available, while headers are only included if present in the request
+	var components []string
+	for _, component := range rs.components {
+		switch {
+		case isDerivedComponent(component):
+			components = append(components, component)
+		case component == contentDigestComponent:
+			// Include 'content-digest' for PUT, POST and PATCH requests only, it is computed when signing
+			if shouldHaveBody(req) {
+				components = append(components, component)
+			}
+		case req.Header.Get(component) != "":
+			components = append(components, component)
+		}
+	}
+	if len(components) == 0 {
+		return nil, proxy.NewInvalidRequestError(fmt.Errorf("none of the signature components found in the request, expected at least one"))
+	}
+
+	return components, nil
+}
+
 func getHashAlgo(algo string) (crypto.Hash, error) {
 	upper := strings.ToUpper(algo)
 	switch upper {
 	case crypto.SHA256.String():
 		return crypto.SHA256, nil
+	case crypto.SHA384.String():
+		return crypto.SHA384, nil
 	case crypto.SHA512.String():
 		return crypto.SHA512, nil
 	default:
@@ -111,17 +346,25 @@ func getHashAlgo(algo string) (crypto.Hash, error) {
 	}
 }
 
-func loadKey(keyFile string) (*rsa.PrivateKey, error) {
-	rawKey, err := os.ReadFile(keyFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read signer key file: %w", err)
-	}
-	decodedKey, _ := pem.Decode(rawKey)
-	key, err := x509.ParsePKCS1PrivateKey(decodedKey.Bytes)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse signer key file: %w", err)
+// getTargetHeader returns the header the signature is put in. Placing it in the Signature header
+// leaves the Authorization header free for credentials set by the client, e.g. a bearer token.
+func getTargetHeader(placement string) (msgsigner.TargetHeader, error) {
+	switch strings.ToLower(placement) {
+	case authorizationPlacement, "":
+		return msgsigner.Authorization, nil
+	case signaturePlacement:
+		return msgsigner.Signature, nil
+	default:
+		return "", fmt.Errorf("unknown signature header placement '%s'", placement)
 	}
-	return key, nil
+}
+
+// isSameKey reports whether both keys have the same public key. All supported public keys implement Equal.
+func isSameKey(key crypto.Signer, other crypto.Signer) bool {
+	pub, ok := key.Public().(interface {
+		Equal(crypto.PublicKey) bool
+	})
+	return ok && pub.Equal(other.Public())
 }
 
 func shouldHaveBody(req *http.Request) bool {
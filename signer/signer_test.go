@@ -32,6 +32,12 @@ func TestGetHashAlgo(t *testing.T) {
 			crypto.SHA256,
 			require.NoError,
 		},
+		{
+			"sha-384",
+			"SHA-384",
+			crypto.SHA384,
+			require.NoError,
+		},
 		{
 			"sha-512",
 			"SHA-512",
@@ -61,12 +67,6 @@ func TestGetHashAlgo(t *testing.T) {
 	}
 }
 
-func TestLoadKey(t *testing.T) {
-	key, err := loadKey("rsa_test.pem")
-	require.NoError(t, err)
-	require.NotNil(t, key)
-}
-
 func TestGetSignatureHeaders(t *testing.T) {
 	dummyUrl := "https://localhost:1234"
 	dummyBody := "{\"name\":\"travis\"}"
@@ -194,13 +194,13 @@ func TestGetSignatureHeaders(t *testing.T) {
 				req.Header.Set(k, v)
 			}
 
-			reqSigner, err := NewRequestSigner(config.SignerConfig{
+			reqSigner, err := NewRequestSigner("test", config.SignerConfig{
 				KeyId:             "dfb4c78a-e141-4144-aa68-8ec605484d63",
 				KeyFilePath:       "rsa_test.pem",
 				BodyDigestAlgo:    "SHA-256",
 				SignatureHashAlgo: "SHA-256",
 				Headers:           test.headerCfg,
-			})
+			}, nil)
 			require.NoError(t, err)
 
 			actualHeaders, err := reqSigner.(*requestSigner).getSignatureHeaders(req)
@@ -210,3 +210,71 @@ func TestGetSignatureHeaders(t *testing.T) {
 		})
 	}
 }
+
+func TestSignRequestHeaderPlacement(t *testing.T) {
+	bearerToken := "Bearer eyJhbGciOiJIUzI1NiJ9"
+
+	tests := []struct {
+		name                  string
+		headerPlacement       string
+		expectedSignature     bool
+		expectedAuthorization string
+	}{
+		{
+			"default placement",
+			"",
+			false,
+			"Signature keyId=",
+		},
+		{
+			"authorization placement",
+			"Authorization",
+			false,
+			"Signature keyId=",
+		},
+		{
+			"signature placement keeps authorization header",
+			"signature",
+			true,
+			bearerToken,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "https://localhost:1234", nil)
+			require.NoError(t, err)
+			req.Header.Set("Host", "localhost")
+			req.Header.Set("Authorization", bearerToken)
+
+			reqSigner, err := NewRequestSigner("test", config.SignerConfig{
+				KeyId:             "dfb4c78a-e141-4144-aa68-8ec605484d63",
+				KeyFilePath:       "rsa_test.pem",
+				BodyDigestAlgo:    "SHA-256",
+				SignatureHashAlgo: "SHA-256",
+				HeaderPlacement:   test.headerPlacement,
+				Headers: config.HeadersConfig{
+					SignatureHeaders: []string{"host"},
+				},
+			}, nil)
+			require.NoError(t, err)
+
+			signedReq, err := reqSigner.SignRequest(req)
+			require.NoError(t, err)
+
+			require.Equal(t, test.expectedSignature, signedReq.Header.Get("Signature") != "")
+			require.True(t, strings.HasPrefix(signedReq.Header.Get("Authorization"), test.expectedAuthorization))
+		})
+	}
+}
+
+func TestNewRequestSignerInvalidHeaderPlacement(t *testing.T) {
+	_, err := NewRequestSigner("test", config.SignerConfig{
+		KeyId:             "dfb4c78a-e141-4144-aa68-8ec605484d63",
+		KeyFilePath:       "rsa_test.pem",
+		BodyDigestAlgo:    "SHA-256",
+		SignatureHashAlgo: "SHA-256",
+		HeaderPlacement:   "cookie",
+	}, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,103 @@
+package signer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+const testKeyId = "dfb4c78a-e141-4144-aa68-8ec605484d63"
+
+// newWatchingRequestSigner returns a signer watching a copy of keyFile, along with the path of the copy.
+func newWatchingRequestSigner(t *testing.T, keyFile string, metricPublisher *MockMetricPublisher) (*requestSigner, string) {
+	keyPath := filepath.Join(t.TempDir(), "key.pem")
+	copyFile(t, keyFile, keyPath)
+
+	reqSigner, err := NewRequestSigner("test", config.SignerConfig{
+		KeyId:             testKeyId,
+		KeyFilePath:       keyPath,
+		BodyDigestAlgo:    "SHA-256",
+		SignatureHashAlgo: "SHA-256",
+		WatchKeyFile:      true,
+		Headers: config.HeadersConfig{
+			SignatureHeaders: []string{"date"},
+		},
+	}, metricPublisher)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, reqSigner.(*requestSigner).Close())
+	})
+
+	return reqSigner.(*requestSigner), keyPath
+}
+
+// copyFile replaces dst with src by renaming a temporary file over it, the way keys are usually rotated.
+func copyFile(t *testing.T, src string, dst string) {
+	content, err := os.ReadFile(src)
+	require.NoError(t, err)
+	tmp := dst + ".tmp"
+	require.NoError(t, os.WriteFile(tmp, content, 0600))
+	require.NoError(t, os.Rename(tmp, dst))
+}
+
+func TestReloadKey(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	reqSigner, keyPath := newWatchingRequestSigner(t, "rsa_test.pem", NewMockMetricPublisher(mockCtrl))
+	oldKey := reqSigner.keys[0].getSigningState().key
+
+	copyFile(t, "rsa_pkcs8_test.pem", keyPath)
+	newKey, err := loadKey("rsa_pkcs8_test.pem", nil)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return isSameKey(reqSigner.keys[0].getSigningState().key, newKey)
+	}, 5*time.Second, 50*time.Millisecond)
+	require.False(t, isSameKey(oldKey, newKey))
+}
+
+func TestReloadKeyInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		keyFile string
+	}{
+		{
+			"not a key",
+			"passphrase_test.txt",
+		},
+		{
+			"unsupported hash for key type",
+			"ed25519_test.pem",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			mockMetricPublisher := NewMockMetricPublisher(mockCtrl)
+			reqSigner, keyPath := newWatchingRequestSigner(t, "rsa_test.pem", mockMetricPublisher)
+			oldState := reqSigner.keys[0].getSigningState()
+
+			reloaded := make(chan struct{}, 1)
+			mockMetricPublisher.EXPECT().IncrementKeyReloadErrorCount("test", testKeyId).Do(func(string, string) {
+				select {
+				case reloaded <- struct{}{}:
+				default:
+				}
+			}).MinTimes(1)
+
+			copyFile(t, test.keyFile, keyPath)
+
+			select {
+			case <-reloaded:
+			case <-time.After(5 * time.Second):
+				t.Fatal("key reload error not reported")
+			}
+			require.Same(t, oldState, reqSigner.keys[0].getSigningState())
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package signingproxy
+
+import (
+	"net"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type options struct {
+	cfg       *config.Config
+	cfgFile   string
+	overrides []string
+	registry  *prometheus.Registry
+	listener  net.Listener
+}
+
+// Option configures a Proxy created by New.
+type Option func(*options)
+
+// WithConfig sets the config of the proxy.
+func WithConfig(cfg *config.Config) Option {
+	return func(o *options) {
+		o.cfg = cfg
+	}
+}
+
+// WithConfigFile loads the config of the proxy from a file, with overrides in the key=value format of the --set flag.
+func WithConfigFile(path string, overrides ...string) Option {
+	return func(o *options) {
+		o.cfgFile = path
+		o.overrides = overrides
+	}
+}
+
+// WithRegistry registers the proxy metrics with registry instead of a new registry. The Go runtime and process
+// metrics are only registered with the new registry, as a registry provided by the caller usually has them already.
+func WithRegistry(registry *prometheus.Registry) Option {
+	return func(o *options) {
+		o.registry = registry
+	}
+}
+
+// WithListener serves the proxy on l rather than on the port set in the config, e.g. on a random port in tests.
+func WithListener(l net.Listener) Option {
+	return func(o *options) {
+		o.listener = l
+	}
+}
@@ -0,0 +1,338 @@
+// Package signingproxy runs the signing proxy inside another Go program, either as a whole proxy or as an
+// http.RoundTripper signing the requests of an http.Client.
+package signingproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"sync"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/auth"
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	"github.com/form3tech-oss/http-message-signing-proxy/metric"
+	"github.com/form3tech-oss/http-message-signing-proxy/proxy"
+	"github.com/form3tech-oss/http-message-signing-proxy/signer"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	log "github.com/sirupsen/logrus"
+)
+
+// routeSigner is the request signer of a route, along with the config it was created from.
+type routeSigner struct {
+	cfg       config.SignerConfig
+	reqSigner proxy.RequestSigner
+}
+
+// configuredRoute is a route along with the config it was created from.
+type configuredRoute struct {
+	cfg   config.RouteConfig
+	route *proxy.Route
+}
+
+// Proxy is a signing proxy, forwarding the requests it receives to their upstream target once signed.
+type Proxy struct {
+	metricPublisher proxy.MetricPublisher
+	handler         proxy.Handler
+	server          *proxy.Server
+	listener        net.Listener
+
+	mu      sync.Mutex
+	cfg     *config.Config
+	routes  map[string]*configuredRoute
+	signers map[string]*routeSigner
+	// rateLimiter is kept when the config is reloaded without changing the rate limits, so that the buckets are not
+	// refilled
+	rateLimiter *proxy.RateLimiter
+	started     bool
+
+	// served is closed once the server is stopped, serveErr is then set if it failed to serve or to shut down
+	served   chan struct{}
+	serveErr error
+}
+
+// New creates a proxy from the config set by WithConfig or WithConfigFile. The config is validated and the signers
+// of all routes are created, but the proxy only serves requests once started.
+func New(opts ...Option) (*Proxy, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	cfg := o.cfg
+	if cfg == nil {
+		if o.cfgFile == "" {
+			return nil, errors.New("either WithConfig or WithConfigFile must be set")
+		}
+		var err error
+		cfg, err = config.LoadConfig(o.cfgFile, o.overrides)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load config: %w", err)
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	registry := o.registry
+	if registry == nil {
+		registry = prometheus.NewRegistry()
+		registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	}
+	metricPublisher := metric.NewMetricPublisher(registry)
+
+	// Routes are set by apply, which replaces them when the config is reloaded
+	handler := proxy.NewHandler(nil, metricPublisher)
+	p := &Proxy{
+		metricPublisher: metricPublisher,
+		handler:         handler,
+		server:          proxy.NewServer(cfg.Server, handler, metricPublisher, registry),
+		listener:        o.listener,
+		routes:          map[string]*configuredRoute{},
+		signers:         map[string]*routeSigner{},
+		served:          make(chan struct{}),
+	}
+	if err := p.apply(cfg); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Start listens and serves requests in the background until ctx is done or Shutdown is called. It returns once the
+// proxy is listening, or with an error if it cannot listen. When ctx is done, requests in progress are given
+// server.shutdownGracePeriod to finish, Wait returns once they are.
+func (p *Proxy) Start(ctx context.Context) error {
+	p.mu.Lock()
+	p.started = true
+	p.mu.Unlock()
+
+	go func() {
+		defer close(p.served)
+		if p.listener != nil {
+			p.serveErr = p.server.StartWithListener(ctx, p.listener)
+		} else {
+			p.serveErr = p.server.Start(ctx)
+		}
+		p.closeSigners()
+	}()
+
+	select {
+	case <-p.server.Ready():
+		return nil
+	case <-p.served:
+		return p.serveErr
+	}
+}
+
+// Wait blocks until the started proxy is stopped, it returns an error if it failed to serve or to shut down.
+func (p *Proxy) Wait() error {
+	<-p.served
+	return p.serveErr
+}
+
+// Shutdown stops the proxy gracefully, waiting for the requests in progress to finish until ctx is done.
+func (p *Proxy) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	started := p.started
+	p.mu.Unlock()
+	if !started {
+		p.closeSigners()
+		return nil
+	}
+
+	log.Info("shutting down server")
+	if err := p.server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to shutdown server: %w", err)
+	}
+	<-p.served
+	log.Info("server stopped")
+	return nil
+}
+
+// closeSigners closes the signers of all routes, once the proxy is stopped.
+func (p *Proxy) closeSigners() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	closeUnusedSigners(p.signers, nil)
+	p.signers = map[string]*routeSigner{}
+}
+
+// Addr returns the address the proxy listens on, nil until it is started.
+func (p *Proxy) Addr() net.Addr {
+	return p.server.ListenAddr()
+}
+
+// Registry returns the registry of the proxy metrics.
+func (p *Proxy) Registry() *prometheus.Registry {
+	return p.server.Registry()
+}
+
+// Reload validates cfg then applies it to the running proxy. Routes, signers, the CORS origin, the client auth,
+// the request filter and the rate limits are replaced without dropping connections, changes to the other server
+// fields are ignored with a warning as they require a restart. The current config is kept if cfg is invalid.
+func (p *Proxy) Reload(cfg *config.Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// The server keeps listening with the config it was started with
+	if cfg.Server.Port != p.cfg.Server.Port {
+		log.WithField("field", "server.port").Warn("config field cannot be changed without a restart, the change is ignored")
+	}
+	if !reflect.DeepEqual(cfg.Server.SSL, p.cfg.Server.SSL) {
+		log.WithField("field", "server.ssl").Warn("config field cannot be changed without a restart, the change is ignored")
+	}
+	if cfg.Server.ShutdownGracePeriod != p.cfg.Server.ShutdownGracePeriod {
+		log.WithField("field", "server.shutdownGracePeriod").Warn("config field cannot be changed without a restart, the change is ignored")
+	}
+	if !reflect.DeepEqual(cfg.Server.TrustedProxies, p.cfg.Server.TrustedProxies) {
+		log.WithField("field", "server.trustedProxies").Warn("config field cannot be changed without a restart, the change is ignored")
+	}
+	reloaded := *cfg
+	reloaded.Server.Port = p.cfg.Server.Port
+	reloaded.Server.SSL = p.cfg.Server.SSL
+	reloaded.Server.ShutdownGracePeriod = p.cfg.Server.ShutdownGracePeriod
+	reloaded.Server.TrustedProxies = p.cfg.Server.TrustedProxies
+
+	return p.apply(&reloaded)
+}
+
+// apply replaces the routes, the CORS origin, the client auth, the request filter and the rate limits of the server
+// with the ones of cfg.
+func (p *Proxy) apply(cfg *config.Config) error {
+	var authenticator proxy.Authenticator
+	var authorizer proxy.Authorizer
+	if cfg.Server.Auth.Enable {
+		var err error
+		authenticator, err = auth.NewAuthenticator(cfg.Server.Auth)
+		if err != nil {
+			return fmt.Errorf("failed to initialise client authentication: %w", err)
+		}
+		authorizer = auth.NewAuthorizer(cfg.Server.Auth)
+	}
+	filter, err := proxy.NewRequestFilter(cfg.Proxy.Filter)
+	if err != nil {
+		return fmt.Errorf("failed to initialise request filter: %w", err)
+	}
+	rateLimiter := p.rateLimiter
+	if p.cfg == nil || !reflect.DeepEqual(cfg.Proxy.RateLimit, p.cfg.Proxy.RateLimit) {
+		rateLimiter, err = proxy.NewRateLimiter(cfg.Proxy.RateLimit)
+		if err != nil {
+			return fmt.Errorf("failed to initialise rate limiter: %w", err)
+		}
+	}
+	handlerRoutes, routes, signers, err := p.buildRoutes(cfg)
+	if err != nil {
+		return err
+	}
+
+	p.handler.SetRoutes(handlerRoutes)
+	p.server.SetAccessControlAllowOrigin(cfg.Server.AccessControlAllowOrigin)
+	p.server.SetAuth(authenticator, authorizer)
+	p.server.SetRequestFilter(filter)
+	p.server.SetRateLimiter(rateLimiter)
+	retireUnusedRoutes(p.routes, routes, p.signers, signers)
+	p.cfg = cfg
+	p.routes = routes
+	p.signers = signers
+	p.rateLimiter = rateLimiter
+	return nil
+}
+
+// buildRoutes creates the routes of cfg, in the order they are matched. Routes whose config did not change are
+// reused, so that their connections and circuit breaker are kept. Signers of routes whose signer config did not
+// change are reused as well, so that key watchers and rotation are not restarted.
+func (p *Proxy) buildRoutes(cfg *config.Config) ([]*proxy.Route, map[string]*configuredRoute, map[string]*routeSigner, error) {
+	var handlerRoutes []*proxy.Route
+	routes := map[string]*configuredRoute{}
+	signers := map[string]*routeSigner{}
+	for _, routeCfg := range cfg.Proxy.GetRoutes() {
+		rs, err := p.getRouteSigner(routeCfg)
+		if err != nil {
+			closeUnusedSigners(signers, p.signers)
+			return nil, nil, nil, fmt.Errorf("failed to initialise request signer of route '%s': %w", routeCfg.Name, err)
+		}
+		signers[routeCfg.Name] = rs
+
+		cr, ok := p.routes[routeCfg.Name]
+		if !ok || !reflect.DeepEqual(cr.cfg, routeCfg) {
+			route, err := proxy.NewRoute(routeCfg, rs.reqSigner, p.metricPublisher)
+			if err != nil {
+				closeUnusedSigners(signers, p.signers)
+				return nil, nil, nil, fmt.Errorf("failed to create signing proxy of route '%s': %w", routeCfg.Name, err)
+			}
+			cr = &configuredRoute{cfg: routeCfg, route: route}
+		}
+		routes[routeCfg.Name] = cr
+		handlerRoutes = append(handlerRoutes, cr.route)
+	}
+	return handlerRoutes, routes, signers, nil
+}
+
+func (p *Proxy) getRouteSigner(routeCfg config.RouteConfig) (*routeSigner, error) {
+	if rs, ok := p.signers[routeCfg.Name]; ok && reflect.DeepEqual(rs.cfg, routeCfg.Signer) {
+		return rs, nil
+	}
+	reqSigner, err := signer.NewRequestSigner(routeCfg.Name, routeCfg.Signer, p.metricPublisher)
+	if err != nil {
+		return nil, err
+	}
+	return &routeSigner{
+		cfg:       routeCfg.Signer,
+		reqSigner: reqSigner,
+	}, nil
+}
+
+// retireUnusedRoutes retires the routes replaced by a reload. Once the requests in progress on them are done, their
+// idle connections are closed, as well as the signers that are not used any more.
+func retireUnusedRoutes(routes map[string]*configuredRoute, usedRoutes map[string]*configuredRoute, signers map[string]*routeSigner, usedSigners map[string]*routeSigner) {
+	var drained []<-chan struct{}
+	var retired []*proxy.Route
+	for name, cr := range routes {
+		if usedRoutes[name] == cr {
+			continue
+		}
+		drained = append(drained, cr.route.Retire())
+		retired = append(retired, cr.route)
+	}
+	unusedSigners := map[string]*routeSigner{}
+	for name, rs := range signers {
+		if usedSigners[name] != rs {
+			unusedSigners[name] = rs
+		}
+	}
+	if len(retired) == 0 && len(unusedSigners) == 0 {
+		return
+	}
+
+	go func() {
+		for _, ch := range drained {
+			<-ch
+		}
+		for _, route := range retired {
+			route.Proxy.CloseIdleConnections()
+		}
+		closeUnusedSigners(unusedSigners, nil)
+	}()
+}
+
+// closeUnusedSigners closes the signers that are not used any more, e.g. signers replaced by a reload.
+func closeUnusedSigners(signers map[string]*routeSigner, used map[string]*routeSigner) {
+	for name, rs := range signers {
+		if used[name] == rs {
+			continue
+		}
+		// Signers watching their key file must stop doing so when they are no longer used
+		if closer, ok := rs.reqSigner.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				log.WithError(err).WithField("route", name).Error("failed to close request signer")
+			}
+		}
+	}
+}
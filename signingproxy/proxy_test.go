@@ -0,0 +1,201 @@
+package signingproxy
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	httpsignatures "github.com/form3tech-oss/go-http-message-signatures"
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// from ../example/config_example.yaml
+	testKeyId      = "6f33b219-137c-467e-9a61-f61040a03363"
+	testCfgFile    = "../example/config_example.yaml"
+	privateKeyFile = "../example/rsa_private_key.pem"
+	publicKeyFile  = "../example/rsa_public_key.pub"
+)
+
+// testTargetServer returns a server responding 200 OK to requests with a valid signature, 400 otherwise.
+func testTargetServer(t *testing.T) *httptest.Server {
+	verifier := httpsignatures.NewMessageVerifier(func(keyID string) (crypto.PublicKey, crypto.Hash, error) {
+		if keyID != testKeyId {
+			return nil, 0, fmt.Errorf("unknown key")
+		}
+		keyBytes, err := os.ReadFile(publicKeyFile)
+		if err != nil {
+			return nil, 0, err
+		}
+		decoded, _ := pem.Decode(keyBytes)
+		pk, err := x509.ParsePKIXPublicKey(decoded.Bytes)
+		return pk, crypto.SHA256, err
+	})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := verifier.VerifyRequest(r); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func testOverrides(upstreamTarget string) []string {
+	return []string{
+		"server.ssl.enable=false",
+		"proxy.signer.keyFilePath=" + privateKeyFile,
+		"proxy.upstreamTarget=" + upstreamTarget,
+	}
+}
+
+// startTestProxy starts a proxy on a random port, forwarding requests to upstreamTarget.
+func startTestProxy(t *testing.T, upstreamTarget string) *Proxy {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	p, err := New(WithConfigFile(testCfgFile, testOverrides(upstreamTarget)...), WithListener(l))
+	require.NoError(t, err)
+	require.NoError(t, p.Start(context.Background()))
+	t.Cleanup(func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	})
+	return p
+}
+
+func sendTestRequest(t *testing.T, p *Proxy) int {
+	req, err := http.NewRequest(http.MethodGet, "http://"+p.Addr().String()+"/test", nil)
+	require.NoError(t, err)
+	req.Header.Set("Date", time.Now().Format(http.TimeFormat))
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestProxy(t *testing.T) {
+	targetSrv := testTargetServer(t)
+
+	// Several proxies can run in the same process
+	p1 := startTestProxy(t, targetSrv.URL)
+	p2 := startTestProxy(t, targetSrv.URL)
+
+	require.Equal(t, http.StatusOK, sendTestRequest(t, p1))
+	require.Equal(t, http.StatusOK, sendTestRequest(t, p2))
+	require.Equal(t, http.StatusOK, sendTestRequest(t, p2))
+
+	// Each proxy has its own metrics
+	for p, expected := range map[*Proxy]float64{p1: 1, p2: 2} {
+		families, err := p.Registry().Gather()
+		require.NoError(t, err)
+		var count float64
+		for _, family := range families {
+			if family.GetName() == "signing_proxy_signed_request_total" {
+				count = family.GetMetric()[0].GetCounter().GetValue()
+			}
+		}
+		require.Equal(t, expected, count)
+	}
+}
+
+func TestProxyShutdown(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	p, err := New(WithConfigFile(testCfgFile, testOverrides(testTargetServer(t).URL)...), WithListener(l))
+	require.NoError(t, err)
+	require.NoError(t, p.Start(context.Background()))
+
+	require.NoError(t, p.Shutdown(context.Background()))
+	require.NoError(t, p.Wait())
+	_, err = http.Get("http://" + p.Addr().String() + "/test")
+	require.Error(t, err)
+}
+
+func TestProxyStartContext(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	p, err := New(WithConfigFile(testCfgFile, testOverrides(testTargetServer(t).URL)...), WithListener(l))
+	require.NoError(t, err)
+
+	// The proxy is stopped when the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, p.Start(ctx))
+	require.Equal(t, http.StatusOK, sendTestRequest(t, p))
+	cancel()
+	require.NoError(t, p.Wait())
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		checkFn func(t *testing.T, err error)
+	}{
+		{
+			"no config",
+			nil,
+			func(t *testing.T, err error) {
+				require.Error(t, err)
+			},
+		},
+		{
+			"invalid config",
+			[]Option{WithConfig(&config.Config{})},
+			func(t *testing.T, err error) {
+				var validationErrs config.ValidationErrors
+				require.ErrorAs(t, err, &validationErrs)
+			},
+		},
+		{
+			"missing key file",
+			[]Option{WithConfigFile(testCfgFile, "server.ssl.enable=false", "proxy.signer.keyFilePath=missing.pem")},
+			func(t *testing.T, err error) {
+				require.Error(t, err)
+			},
+		},
+		{
+			"valid config",
+			[]Option{WithConfigFile(testCfgFile, testOverrides("http://localhost")...)},
+			func(t *testing.T, err error) {
+				require.NoError(t, err)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := New(test.opts...)
+			test.checkFn(t, err)
+		})
+	}
+}
+
+func TestProxyReload(t *testing.T) {
+	p := startTestProxy(t, testTargetServer(t).URL)
+	route := p.routes["default"].route
+	rs := p.signers["default"]
+
+	// Unchanged routes are kept, along with their connections and circuit breaker
+	cfg := *p.cfg
+	require.NoError(t, p.Reload(&cfg))
+	require.Same(t, route, p.routes["default"].route)
+	require.Same(t, rs, p.signers["default"])
+
+	// Routes whose upstream changes are replaced, their signer is kept
+	cfg.Proxy.Upstream.Transport.IdleConnTimeout = time.Minute
+	require.NoError(t, p.Reload(&cfg))
+	require.NotSame(t, route, p.routes["default"].route)
+	require.Same(t, rs, p.signers["default"])
+	require.Equal(t, http.StatusOK, sendTestRequest(t, p))
+}
@@ -0,0 +1,55 @@
+package signingproxy
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/proxy"
+)
+
+// SigningTransport is an http.RoundTripper signing requests before sending them with Base, so that an http.Client
+// signs its requests the same way the proxy does.
+type SigningTransport struct {
+	// ReqSigner signs the requests, e.g. created by signer.NewRequestSigner
+	ReqSigner proxy.RequestSigner
+	// Base sends the signed requests, http.DefaultTransport if nil
+	Base http.RoundTripper
+}
+
+// NewSigningTransport returns a transport signing requests with reqSigner before sending them with base.
+func NewSigningTransport(reqSigner proxy.RequestSigner, base http.RoundTripper) *SigningTransport {
+	return &SigningTransport{
+		ReqSigner: reqSigner,
+		Base:      base,
+	}
+}
+
+// RoundTrip signs a copy of req, as a RoundTripper must not modify the request, then sends it.
+// Requests that cannot be signed are not sent and their body is closed.
+func (t *SigningTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	signReq := req.Clone(req.Context())
+
+	// The Host header is not set by clients but is usually signed, in the same way as ForwardRequest does
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	signReq.Header.Set("Host", host)
+	if signReq.Header.Get("Date") == "" {
+		signReq.Header.Set("Date", time.Now().Format(http.TimeFormat))
+	}
+
+	signedReq, err := t.ReqSigner.SignRequest(signReq)
+	if err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, err
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(signedReq)
+}
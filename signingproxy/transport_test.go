@@ -0,0 +1,71 @@
+package signingproxy
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/config"
+	"github.com/form3tech-oss/http-message-signing-proxy/signer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigningTransport(t *testing.T) {
+	targetSrv := testTargetServer(t)
+	reqSigner, err := signer.NewRequestSigner("test", config.SignerConfig{
+		KeyId:             testKeyId,
+		KeyFilePath:       privateKeyFile,
+		BodyDigestAlgo:    "SHA-256",
+		SignatureHashAlgo: "SHA-256",
+		Headers: config.HeadersConfig{
+			IncludeRequestTarget: true,
+			SignatureHeaders:     []string{"host", "date"},
+		},
+	}, nil)
+	require.NoError(t, err)
+	client := &http.Client{Transport: NewSigningTransport(reqSigner, nil)}
+
+	req, err := http.NewRequest(http.MethodGet, targetSrv.URL+"/test", nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// The request of the caller is left untouched
+	require.Empty(t, req.Header.Get("Authorization"))
+	require.Empty(t, req.Header.Get("Date"))
+}
+
+func TestSigningTransportInvalidRequest(t *testing.T) {
+	reqSigner, err := signer.NewRequestSigner("test", config.SignerConfig{
+		KeyId:             testKeyId,
+		KeyFilePath:       privateKeyFile,
+		BodyDigestAlgo:    "SHA-256",
+		SignatureHashAlgo: "SHA-256",
+		Headers: config.HeadersConfig{
+			SignatureHeaders: []string{"accept"},
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	// Requests that cannot be signed are not sent
+	sent := false
+	base := roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		sent = true
+		return nil, nil
+	})
+	req, err := http.NewRequest(http.MethodGet, "http://localhost/test", nil)
+	require.NoError(t, err)
+	req.Header.Set("Date", time.Now().Format(http.TimeFormat))
+
+	_, err = NewSigningTransport(reqSigner, base).RoundTrip(req)
+	require.Error(t, err)
+	require.False(t, sent)
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
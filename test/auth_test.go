@@ -0,0 +1,105 @@
+package test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/signingproxy"
+	"github.com/stretchr/testify/require"
+)
+
+func TestE2EAuth(t *testing.T) {
+	// Test target that fails if the API key is forwarded
+	targetSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer targetSrv.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	p, err := signingproxy.New(signingproxy.WithConfigFile(cfgFile,
+		"server.ssl.enable=false",
+		"server.auth.enable=true",
+		"server.auth.apiKeys.keys=[{client: billing, key: billing-key}, {client: reports, key: reports-key}]",
+		"server.auth.clients=[{name: billing, rules: [{methods: [GET, POST], pathPrefix: /test}]}]",
+		"proxy.signer.keyFilePath="+privateKeyFile,
+		"proxy.upstreamTarget="+targetSrv.URL,
+	), signingproxy.WithListener(l))
+	require.NoError(t, err)
+	require.NoError(t, p.Start(context.Background()))
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	tests := []struct {
+		name             string
+		method           string
+		apiKey           string
+		expectedStatus   int
+		expectedRespBody string
+	}{
+		{
+			"allowed client",
+			http.MethodPost,
+			"billing-key",
+			http.StatusOK,
+			"",
+		},
+		{
+			"missing API key",
+			http.MethodPost,
+			"",
+			http.StatusUnauthorized,
+			`{"error":"unauthorized: missing credentials"}`,
+		},
+		{
+			"invalid API key",
+			http.MethodPost,
+			"unknown-key",
+			http.StatusUnauthorized,
+			`{"error":"unauthorized: invalid API key"}`,
+		},
+		{
+			"method not allowed",
+			http.MethodDelete,
+			"billing-key",
+			http.StatusForbidden,
+			`{"error":"forbidden: client 'billing' is not allowed to DELETE /test/path"}`,
+		},
+		{
+			"client without rules",
+			http.MethodGet,
+			"reports-key",
+			http.StatusForbidden,
+			`{"error":"forbidden: client 'reports' is not allowed to GET /test/path"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, "http://"+p.Addr().String()+testPath, nil)
+			require.NoError(t, err)
+			req.Header.Set("Date", time.Now().Format(http.TimeFormat))
+			if test.apiKey != "" {
+				req.Header.Set("X-API-Key", test.apiKey)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			b, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			require.Equal(t, test.expectedStatus, resp.StatusCode)
+			require.Equal(t, test.expectedRespBody, string(b))
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/signingproxy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestE2EClientAuth(t *testing.T) {
+	// Test target that accepts any request
+	targetSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer targetSrv.Close()
+
+	ca := newTestCA(t)
+	_, serverCertFile, serverKeyFile := ca.issue(t, "localhost", x509.ExtKeyUsageServerAuth)
+	allowedCert, _, _ := ca.issue(t, "allowed.example.com", x509.ExtKeyUsageClientAuth)
+	otherCert, _, _ := ca.issue(t, "other.example.com", x509.ExtKeyUsageClientAuth)
+	untrustedCert, _, _ := newTestCA(t).issue(t, "allowed.example.com", x509.ExtKeyUsageClientAuth)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	p, err := signingproxy.New(signingproxy.WithConfigFile(cfgFile,
+		"server.ssl.enable=true",
+		"server.ssl.certFilePath="+serverCertFile,
+		"server.ssl.keyFilePath="+serverKeyFile,
+		"server.ssl.clientAuth.enable=true",
+		"server.ssl.clientAuth.caFilePath="+ca.bundle(t),
+		"server.ssl.clientAuth.allowedNames=[allowed.example.com]",
+		"proxy.signer.keyFilePath="+privateKeyFile,
+		"proxy.upstreamTarget="+targetSrv.URL,
+	), signingproxy.WithListener(l))
+	require.NoError(t, err)
+	require.NoError(t, p.Start(context.Background()))
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	send := func(clientCerts []tls.Certificate) (int, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      ca.pool(),
+			ServerName:   "localhost",
+			Certificates: clientCerts,
+		}}}
+		req, err := http.NewRequest(http.MethodGet, "https://"+p.Addr().String()+testPath, nil)
+		require.NoError(t, err)
+		req.Header.Set("Date", time.Now().Format(http.TimeFormat))
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	status, err := send([]tls.Certificate{allowedCert})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, status)
+
+	// Clients without a certificate, with a certificate of another CA or with a name not allowed are rejected
+	for _, certs := range [][]tls.Certificate{nil, {untrustedCert}, {otherCert}} {
+		_, err = send(certs)
+		require.Error(t, err)
+	}
+
+	// Requests are counted per client
+	count, err := testutil.GatherAndCount(p.Registry(), "signing_proxy_client_request_total")
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+}
@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -22,8 +23,7 @@ import (
 
 const (
 	// from ../example/config_example.yaml
-	keyId     = "6f33b219-137c-467e-9a61-f61040a03363"
-	proxyHost = "https://localhost:8080"
+	keyId = "6f33b219-137c-467e-9a61-f61040a03363"
 
 	cfgFile        = "../example/config_example.yaml"
 	sslCertFile    = "../example/cert.crt"
@@ -31,13 +31,23 @@ const (
 	privateKeyFile = "../example/rsa_private_key.pem"
 	publicKeyFile  = "../example/rsa_public_key.pub"
 
-	testPath = "/test/path?query=bojack"
-	testBody = `{"content": "something"}`
+	testPath        = "/test/path?query=bojack"
+	testBody        = `{"content": "something"}`
+	testBearerToken = "Bearer eyJhbGciOiJIUzI1NiJ9"
+
+	authorizationPlacement = "authorization"
+	signaturePlacement     = "signature"
 )
 
 type e2eTestSuite struct {
 	suite.Suite
+	port                     int
 	accessControlAllowOrigin string
+	headerPlacement          string
+}
+
+func (s *e2eTestSuite) proxyHost() string {
+	return fmt.Sprintf("https://localhost:%d", s.port)
 }
 
 func (s *e2eTestSuite) msgVerifier() *httpsignatures.MessageVerifier {
@@ -92,6 +102,8 @@ func (s *e2eTestSuite) runProxy(upstreamTarget string) {
 			"proxy.signer.keyFilePath":        privateKeyFile,
 			"proxy.upstreamTarget":            upstreamTarget,
 			"server.accessControlAllowOrigin": s.accessControlAllowOrigin,
+			"server.port":                     strconv.Itoa(s.port),
+			"proxy.signer.headerPlacement":    s.headerPlacement,
 		})...,
 	))
 	go func() {
@@ -114,7 +126,7 @@ func (s *e2eTestSuite) SetupSuite() {
 }
 
 func (s *e2eTestSuite) TestHealth() {
-	req, err := http.NewRequest(http.MethodGet, proxyHost+"/-/health", nil)
+	req, err := http.NewRequest(http.MethodGet, s.proxyHost()+"/-/health", nil)
 	s.NoError(err)
 	r, err := http.DefaultClient.Do(req)
 	s.NoError(err)
@@ -123,47 +135,72 @@ func (s *e2eTestSuite) TestHealth() {
 	s.JSONEq(`{"status": "up"}`, string(b))
 }
 
+func (s *e2eTestSuite) TestMetrics() {
+	// Each proxy exposes the metrics of its own registry
+	req, err := http.NewRequest(http.MethodGet, s.proxyHost()+"/-/prometheus", nil)
+	s.NoError(err)
+	r, err := http.DefaultClient.Do(req)
+	s.NoError(err)
+	s.Equal(http.StatusOK, r.StatusCode)
+	b, err := io.ReadAll(r.Body)
+	s.NoError(err)
+	s.Contains(string(b), "go_goroutines")
+}
+
 func (s *e2eTestSuite) TestProxy() {
-	tests := []struct {
+	type proxyTest struct {
 		name           string
 		method         string
 		header         http.Header
 		body           string
 		expectedStatus int
-	}{
+	}
+	tests := []proxyTest{
 		{
 			"GET with multiple valid headers",
 			http.MethodGet,
-			genDefaultHeader(),
+			s.genDefaultHeader(),
 			"",
 			http.StatusOK,
 		},
 		{
 			"PUT with multiple valid headers",
 			http.MethodPut,
-			genDefaultHeader(),
+			s.genDefaultHeader(),
 			testBody,
 			http.StatusOK,
 		},
 		{
 			"POST with multiple valid headers",
 			http.MethodPost,
-			genDefaultHeader(),
+			s.genDefaultHeader(),
 			testBody,
 			http.StatusOK,
 		},
 		{
 			"PATCH with multiple valid headers",
 			http.MethodPatch,
-			genDefaultHeader(),
+			s.genDefaultHeader(),
 			testBody,
 			http.StatusOK,
 		},
 	}
+	if s.headerPlacement == signaturePlacement {
+		// The client's Authorization header must be forwarded untouched
+		header := s.genDefaultHeader()
+		header.Set("Authorization", testBearerToken)
+		tests = append(tests, proxyTest{
+			"POST with client authorization header",
+			http.MethodPost,
+			header,
+			testBody,
+			http.StatusOK,
+		})
+	}
 
 	for _, test := range tests {
 		s.T().Run(test.name, func(t *testing.T) {
-			req, err := http.NewRequest(test.method, proxyHost+testPath, strings.NewReader(test.body))
+			req, err := http.NewRequest(test.method, s.proxyHost()+testPath, strings.NewReader(test.body))
 			s.NoError(err)
 			req.Header = test.header
 			r, err := http.DefaultClient.Do(req)
@@ -183,13 +220,33 @@ func (s *e2eTestSuite) TestProxy() {
 				origHeader := test.header.Clone()
 				origHeader.Del("host")
 				s.Truef(headerContains(resp.Header, origHeader), "some original headers are not preserved")
+
+				// Check if the signature is in the configured header
+				if s.headerPlacement == signaturePlacement {
+					s.NotEmpty(resp.Header.Get("Signature"))
+				} else {
+					s.True(strings.HasPrefix(resp.Header.Get("Authorization"), "Signature "))
+					s.Empty(resp.Header.Get("Signature"))
+				}
 			}
 		})
 	}
 }
 
 func TestE2ETestSuite(t *testing.T) {
-	suite.Run(t, &e2eTestSuite{accessControlAllowOrigin: "*"})
+	suite.Run(t, &e2eTestSuite{
+		port:                     8080,
+		accessControlAllowOrigin: "*",
+		headerPlacement:          authorizationPlacement,
+	})
+}
+
+func TestE2ESignatureHeaderPlacementTestSuite(t *testing.T) {
+	suite.Run(t, &e2eTestSuite{
+		port:                     8081,
+		accessControlAllowOrigin: "*",
+		headerPlacement:          signaturePlacement,
+	})
 }
 
 func genSetFlags(m map[string]string) []string {
@@ -208,9 +265,9 @@ func genHeader(headerMap map[string]string) http.Header {
 	return h
 }
 
-func genDefaultHeader() http.Header {
+func (s *e2eTestSuite) genDefaultHeader() http.Header {
 	return genHeader(map[string]string{
-		"host":   proxyHost,
+		"host":   s.proxyHost(),
 		"date":   time.Now().Format(time.RFC1123),
 		"accept": "application/json",
 	})
@@ -0,0 +1,89 @@
+package test
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/cmd"
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestE2EConfigReload(t *testing.T) {
+	// Test target that accepts any request
+	targetSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer targetSrv.Close()
+
+	example, err := os.ReadFile(cfgFile)
+	require.NoError(t, err)
+	writeConfig := func(path string, accessControlAllowOrigin string, port string) {
+		content := strings.Replace(string(example), `accessControlAllowOrigin: "*"`, `accessControlAllowOrigin: "`+accessControlAllowOrigin+`"`, 1)
+		content = strings.Replace(content, "port: 8080", "port: "+port, 1)
+		tmp := path + ".tmp"
+		require.NoError(t, os.WriteFile(tmp, []byte(content), 0600))
+		require.NoError(t, os.Rename(tmp, path))
+	}
+
+	// Run proxy watching a copy of the example config
+	reloadCfgFile := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(reloadCfgFile, "https://first.example", "8082")
+	rootCmd := cmd.NewRootCmd()
+	rootCmd.SetArgs(append(
+		[]string{"--config", reloadCfgFile, "--watch-config"},
+		genSetFlags(map[string]string{
+			"server.ssl.certFilePath":  sslCertFile,
+			"server.ssl.keyFilePath":   sslKeyFile,
+			"proxy.signer.keyFilePath": privateKeyFile,
+			"proxy.upstreamTarget":     targetSrv.URL,
+		})...,
+	))
+	go func() {
+		require.NoError(t, rootCmd.Execute())
+	}()
+
+	// Skip cert verification because we use a self-signed certificate
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	origin := func() string {
+		req, err := http.NewRequest(http.MethodGet, "https://localhost:8082"+testPath, nil)
+		require.NoError(t, err)
+		req.Header.Set("Date", time.Now().Format(time.RFC1123))
+		r, err := client.Do(req)
+		if err != nil {
+			return ""
+		}
+		defer r.Body.Close()
+		return r.Header.Get("Access-Control-Allow-Origin")
+	}
+	require.Eventually(t, func() bool {
+		return origin() == "https://first.example"
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// The origin is replaced while the port change is ignored, as the server keeps running
+	writeConfig(reloadCfgFile, "https://second.example", "8083")
+	require.Eventually(t, func() bool {
+		return origin() == "https://second.example"
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// An invalid config is not applied, once its reload is attempted
+	hook := logtest.NewGlobal()
+	defer log.StandardLogger().ReplaceHooks(log.LevelHooks{})
+	require.NoError(t, os.WriteFile(reloadCfgFile, []byte("server:\n  port: 0\n"), 0600))
+	require.Eventually(t, func() bool {
+		for _, entry := range hook.AllEntries() {
+			if entry.Message == "failed to reload config, keeping the current config" {
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 50*time.Millisecond)
+	require.Equal(t, "https://second.example", origin())
+}
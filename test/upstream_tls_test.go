@@ -0,0 +1,230 @@
+package test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/http-message-signing-proxy/signingproxy"
+	"github.com/stretchr/testify/require"
+)
+
+// testCA issues the certificates of the upstream TLS tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	dir  string
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key, dir: t.TempDir()}
+}
+
+// issue returns a certificate signed by the CA, along with the paths of its PEM encoded certificate and key.
+func (ca *testCA) issue(t *testing.T, name string, usage x509.ExtKeyUsage) (tls.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	certFile := filepath.Join(ca.dir, name+".crt")
+	keyFile := filepath.Join(ca.dir, name+".key")
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0600))
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+	return cert, certFile, keyFile
+}
+
+// bundle returns the path of the PEM encoded CA certificate.
+func (ca *testCA) bundle(t *testing.T) string {
+	path := filepath.Join(ca.dir, "ca.crt")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0600))
+	return path
+}
+
+func (ca *testCA) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+func TestE2EUpstreamTLS(t *testing.T) {
+	ca := newTestCA(t)
+	caFile := ca.bundle(t)
+	serverCert, _, _ := ca.issue(t, "example.com", x509.ExtKeyUsageServerAuth)
+	_, clientCertFile, clientKeyFile := ca.issue(t, "proxy.example.com", x509.ExtKeyUsageClientAuth)
+
+	// Test target accepting any request over TLS
+	targetServer := func(t *testing.T, tlsCfg *tls.Config) *httptest.Server {
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		tlsCfg.Certificates = []tls.Certificate{serverCert}
+		srv.TLS = tlsCfg
+		srv.StartTLS()
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	mTLSConfig := func() *tls.Config {
+		return &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: ca.pool()}
+	}
+
+	tests := []struct {
+		name           string
+		serverTLS      *tls.Config
+		overrides      []string
+		expectedStatus int
+	}{
+		{
+			"custom CA",
+			&tls.Config{},
+			[]string{"proxy.upstream.tls.caFilePath=" + caFile},
+			http.StatusOK,
+		},
+		{
+			"unknown CA",
+			&tls.Config{},
+			nil,
+			http.StatusBadGateway,
+		},
+		{
+			"client certificate",
+			mTLSConfig(),
+			[]string{
+				"proxy.upstream.tls.caFilePath=" + caFile,
+				"proxy.upstream.tls.certFilePath=" + clientCertFile,
+				"proxy.upstream.tls.keyFilePath=" + clientKeyFile,
+			},
+			http.StatusOK,
+		},
+		{
+			"missing client certificate",
+			mTLSConfig(),
+			[]string{"proxy.upstream.tls.caFilePath=" + caFile},
+			http.StatusBadGateway,
+		},
+		{
+			"server name",
+			&tls.Config{},
+			[]string{
+				"proxy.upstream.tls.caFilePath=" + caFile,
+				"proxy.upstream.tls.serverName=example.com",
+			},
+			http.StatusOK,
+		},
+		{
+			"wrong server name",
+			&tls.Config{},
+			[]string{
+				"proxy.upstream.tls.caFilePath=" + caFile,
+				"proxy.upstream.tls.serverName=other.example.com",
+			},
+			http.StatusBadGateway,
+		},
+		{
+			"minimum version not supported by the server",
+			&tls.Config{MaxVersion: tls.VersionTLS12},
+			[]string{
+				"proxy.upstream.tls.caFilePath=" + caFile,
+				"proxy.upstream.tls.minVersion=1.3",
+			},
+			http.StatusBadGateway,
+		},
+		{
+			"insecure skip verify in dev mode",
+			&tls.Config{},
+			[]string{
+				"devMode=true",
+				"proxy.upstream.tls.insecureSkipVerify=true",
+			},
+			http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			targetSrv := targetServer(t, test.serverTLS)
+			p := startUpstreamTLSProxy(t, append(upstreamTLSOverrides(targetSrv.URL), test.overrides...))
+
+			req, err := http.NewRequest(http.MethodGet, "http://"+p.Addr().String()+testPath, nil)
+			require.NoError(t, err)
+			req.Header.Set("Date", time.Now().Format(http.TimeFormat))
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, test.expectedStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestE2EUpstreamTLSInsecureSkipVerifyRequiresDevMode(t *testing.T) {
+	_, err := signingproxy.New(signingproxy.WithConfigFile(cfgFile,
+		append(upstreamTLSOverrides("https://localhost"), "proxy.upstream.tls.insecureSkipVerify=true")...,
+	))
+	require.ErrorContains(t, err, "proxy.upstream.tls.insecureSkipVerify")
+}
+
+func upstreamTLSOverrides(upstreamTarget string) []string {
+	return []string{
+		"server.ssl.enable=false",
+		"proxy.signer.keyFilePath=" + privateKeyFile,
+		"proxy.upstreamTarget=" + upstreamTarget,
+	}
+}
+
+// startUpstreamTLSProxy starts a proxy without TLS on a random port.
+func startUpstreamTLSProxy(t *testing.T, overrides []string) *signingproxy.Proxy {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	p, err := signingproxy.New(signingproxy.WithConfigFile(cfgFile, overrides...), signingproxy.WithListener(l))
+	require.NoError(t, err)
+	require.NoError(t, p.Start(context.Background()))
+	t.Cleanup(func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	})
+	return p
+}
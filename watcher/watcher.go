@@ -0,0 +1,71 @@
+package watcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+)
+
+// reloadDelay is how long the watcher waits for the file to settle before calling onChange,
+// as replacing a file usually triggers several events.
+const reloadDelay = 100 * time.Millisecond
+
+// FileWatcher calls onChange when a file is written, replaced or recreated.
+type FileWatcher struct {
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+}
+
+func NewFileWatcher(file string, onChange func()) (*FileWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+	}
+
+	// The directory is watched rather than the file itself, so that the file can be replaced atomically,
+	// e.g. by renaming a new file over it or by updating a Kubernetes secret or config map volume.
+	if err := watcher.Add(filepath.Dir(file)); err != nil {
+		_ = watcher.Close()
+		return nil, err
+	}
+
+	w := &FileWatcher{
+		watcher: watcher,
+		done:    make(chan struct{}),
+	}
+	go w.run(onChange)
+	return w, nil
+}
+
+func (w *FileWatcher) run(onChange func()) {
+	defer close(w.done)
+
+	var reload <-chan time.Time
+	for {
+		select {
+		case _, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			reload = time.After(reloadDelay)
+		case <-reload:
+			reload = nil
+			onChange()
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.WithError(err).Error("file watcher error")
+		}
+	}
+}
+
+// Close stops the watcher and waits for any onChange call in progress to finish.
+func (w *FileWatcher) Close() error {
+	err := w.watcher.Close()
+	<-w.done
+	return err
+}